@@ -1,4 +1,4 @@
-// Copyright (c) 2017-2023 The Decred developers
+// Copyright (c) 2017-2025 The Decred developers
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
@@ -10,10 +10,10 @@ import (
 	"html/template"
 	"log"
 	"regexp"
-	"strings"
+	"time"
 
+	"github.com/decred/dcrd/dcrjson/v4"
 	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
-	"github.com/decred/dcrd/rpcclient/v8"
 )
 
 // Agenda contains all of the data representing an agenda for the html
@@ -21,12 +21,18 @@ import (
 type Agenda struct {
 	ID     string
 	Status string
-	// Title is the main heading for the agenda card, hard-coded in main.go.
+	// Title is the main heading for the agenda card, from the agenda
+	// catalog.
 	Title string
 	// Description is the short description from dcrd GetVoteInfo RPC response.
 	Description string
-	// LongDescription is a more verbose description, hard-coded in main.go.
+	// LongDescription is a more verbose description, from the agenda
+	// catalog.
 	LongDescription template.HTML
+	// DCPs and Proposals link to the specification and proposals of the
+	// agenda, from the agenda catalog.
+	DCPs            []DCP
+	Proposals       []ProposalLink
 	Mask            uint16
 	VoteVersion     uint32
 	QuorumThreshold int64
@@ -34,6 +40,121 @@ type Agenda struct {
 	EndHeight       int64
 	VoteChoices     map[string]VoteChoice
 	VoteCounts      map[string]int64
+	// InvalidVotes are the votes of the agenda's vote version whose bits
+	// match none of its choices.  OtherVersionVotes are the votes of other
+	// vote versions cast during the voting window.  Neither are counted in
+	// VoteCounts.
+	InvalidVotes      int64
+	OtherVersionVotes int64
+	// Progression is the running total of VoteCounts during the voting
+	// window.
+	Progression VoteProgression
+	// Projection extrapolates the votes to the end of the voting window.
+	// It is nil unless the voting window is in progress.
+	Projection *VoteProjection
+}
+
+// voteProgressionPoints is the maximum number of points in the progression of
+// the votes of an agenda.  The votes of consecutive blocks are grouped into
+// buckets so that the full voting window can be covered.
+const voteProgressionPoints = 100
+
+// VoteProgression is the cumulative vote counts of an agenda at points during
+// its voting window.
+type VoteProgression struct {
+	// BucketSize is the number of blocks between points.
+	BucketSize int64 `json:"bucket_size"`
+	// Heights are the heights of the final block counted at each point,
+	// ending with the most recent block counted.
+	Heights []int64 `json:"heights"`
+	// Counts are the vote counts at each point, keyed by vote choice ID.
+	Counts map[string][]int64 `json:"counts"`
+	// Invalid and OtherVersion are the number of invalid votes and votes of
+	// other vote versions at each point.
+	Invalid      []int64 `json:"invalid"`
+	OtherVersion []int64 `json:"other_version"`
+}
+
+// complete returns whether the progression has a value of every series at each
+// of its points, which is not the case for progressions cached before a series
+// was added.
+func (p *VoteProgression) complete() bool {
+	n := len(p.Heights)
+	if n == 0 || len(p.Invalid) != n || len(p.OtherVersion) != n {
+		return false
+	}
+	for _, counts := range p.Counts {
+		if len(counts) != n {
+			return false
+		}
+	}
+	return true
+}
+
+// progressionCount returns the number of votes for a choice at a point of the
+// vote progression, or zero if the agenda has no such choice.
+func (a *Agenda) progressionCount(voteID string, point int) int64 {
+	counts := a.Progression.Counts[voteID]
+	if point >= len(counts) {
+		return 0
+	}
+	return counts[point]
+}
+
+// ApprovalProgression returns the approval rating, as a percentage, at each
+// point of the vote progression.
+func (a *Agenda) ApprovalProgression() []float64 {
+	approval := make([]float64, len(a.Progression.Heights))
+	for i := range approval {
+		yes, no := a.progressionCount("yes", i), a.progressionCount("no", i)
+		if yes+no > 0 {
+			approval[i] = 100 * float64(yes) / float64(yes+no)
+		}
+	}
+	return approval
+}
+
+// QuorumProgression returns the number of non-abstain votes, as a percentage
+// of the quorum threshold, at each point of the vote progression.
+func (a *Agenda) QuorumProgression() []float64 {
+	quorum := make([]float64, len(a.Progression.Heights))
+	if a.QuorumThreshold == 0 {
+		return quorum
+	}
+	for i := range quorum {
+		nonAbstain := a.progressionCount("yes", i) + a.progressionCount("no", i)
+		quorum[i] = 100 * float64(nonAbstain) / float64(a.QuorumThreshold)
+	}
+	return quorum
+}
+
+// voteChoice returns the ID of the choice made on the agenda by a vote of its
+// vote version with the provided bits, or false if they match none of its
+// choices.
+func (a *Agenda) voteChoice(bits uint16) (string, bool) {
+	for vID, choice := range a.VoteChoices {
+		if bits&a.Mask == choice.Bits {
+			return vID, true
+		}
+	}
+	return "", false
+}
+
+// tallyVote adds a vote to counts, keyed by the ID of the choice it makes on
+// the agenda, or to invalid if its bits match none of the choices, or to
+// otherVersion if it is of another vote version.
+func (a *Agenda) tallyVote(vote types.VersionBits, counts map[string]int64,
+	invalid, otherVersion *int64) {
+
+	if vote.Version != a.VoteVersion {
+		*otherVersion++
+		return
+	}
+	if vID, ok := a.voteChoice(vote.Bits); ok {
+		counts[vID]++
+	} else {
+		*invalid++
+	}
 }
 
 // VoteChoice contains the details of a vote choice from an agenda,
@@ -42,6 +163,9 @@ type VoteChoice struct {
 	ID          string
 	Description string
 	Bits        uint16
+	// Explanation describes the effect of the choice, from the agenda
+	// catalog.
+	Explanation string
 }
 
 var dcpRE = regexp.MustCompile(`(?i)DCP\-?(\d{4})`)
@@ -140,43 +264,83 @@ func (a *Agenda) DescriptionWithDCPURL() template.HTML {
 	return template.HTML(dcpRE.ReplaceAllString(a.Description, subst))
 }
 
-// CountVotes uses the dcrd client to find all yes/no/abstain votes
+// CountVotes uses the chain source to find all yes/no/abstain votes
 // cast against this agenda. It will count the votes and store the
-// totals inside the Agenda
-func (a *Agenda) countVotes(ctx context.Context, dcrdClient *rpcclient.Client, votingStartHeight int64, votingEndHeight int64) error {
+// totals and their progression inside the Agenda. The totals of voting
+// windows which ended at least final blocks before currentHeight are cached.
+func (a *Agenda) countVotes(ctx context.Context, chain chainSource, cache *voteCache,
+	votingStartHeight int64, votingEndHeight int64, currentHeight int64) error {
+
 	// Find the last block hash of this voting period
 	// Required to call GetStakeVersions
-	lastBlockHash, err := dcrdClient.GetBlockHash(ctx, votingEndHeight)
+	lastBlockHash, err := chain.GetBlockHash(ctx, votingEndHeight)
 	if err != nil {
 		return fmt.Errorf("GetBlockHash error: %v", err)
 	}
 
+	// Only the totals of voting windows which have ended can be cached.
+	cacheable := votingEndHeight == a.EndHeight && isFinal(votingEndHeight, currentHeight)
+	if cacheable {
+		if tally, ok := cache.tally(a.ID, a.VoteVersion, lastBlockHash.String()); ok {
+			for vID, n := range tally.Counts {
+				a.VoteCounts[vID] = n
+			}
+			a.Progression = tally.Progression
+			if n := len(a.Progression.Heights); n > 0 {
+				a.InvalidVotes = a.Progression.Invalid[n-1]
+				a.OtherVersionVotes = a.Progression.OtherVersion[n-1]
+			}
+			log.Printf("\tUsing cached vote counts: %v", tally.Counts)
+			return nil
+		}
+	}
+
 	// Retrieve all votes for this voting period
-	stakeVersions, err := dcrdClient.GetStakeVersions(ctx, lastBlockHash.String(), int32(votingEndHeight-votingStartHeight)+1)
+	stakeVersions, err := chain.GetStakeVersions(ctx, lastBlockHash.String(), int32(votingEndHeight-votingStartHeight)+1)
 	if err != nil {
 		return fmt.Errorf("GetStakeVersions error: %v", err)
 	}
 
-	// Collect all votes of the correct version
-	var votes []types.VersionBits
-	for _, sVer := range stakeVersions.StakeVersions {
-		for _, vote := range sVer.Votes {
-			if vote.Version == a.VoteVersion {
-				votes = append(votes, vote)
-			}
-		}
+	// Count the votes of the correct version, oldest block first, recording
+	// the running totals at the end of each bucket of blocks.
+	bucketSize := max(1, (a.EndHeight-a.StartHeight+voteProgressionPoints)/voteProgressionPoints)
+	a.Progression = VoteProgression{
+		BucketSize: bucketSize,
+		Counts:     make(map[string][]int64, len(a.VoteChoices)),
 	}
-
-	// Count the votes and store the total
 	for vID := range a.VoteChoices {
-		var matchingVotes int64
-		for _, vote := range votes {
-			if vote.Bits&a.Mask == a.VoteChoices[vID].Bits {
-				matchingVotes++
+		a.VoteCounts[vID] = 0
+	}
+	blocks := stakeVersions.StakeVersions
+	for i := len(blocks) - 1; i >= 0; i-- {
+		for _, vote := range blocks[i].Votes {
+			a.tallyVote(vote, a.VoteCounts, &a.InvalidVotes, &a.OtherVersionVotes)
+		}
+
+		height := blocks[i].Height
+		if (height-votingStartHeight+1)%bucketSize == 0 || i == 0 {
+			a.Progression.Heights = append(a.Progression.Heights, height)
+			for vID := range a.VoteChoices {
+				a.Progression.Counts[vID] = append(a.Progression.Counts[vID], a.VoteCounts[vID])
 			}
+			a.Progression.Invalid = append(a.Progression.Invalid, a.InvalidVotes)
+			a.Progression.OtherVersion = append(a.Progression.OtherVersion, a.OtherVersionVotes)
 		}
-		a.VoteCounts[vID] = matchingVotes
-		log.Printf("\t%s: %d", vID, matchingVotes)
+	}
+	for vID := range a.VoteChoices {
+		log.Printf("\t%s: %d", vID, a.VoteCounts[vID])
+	}
+	if a.InvalidVotes != 0 || a.OtherVersionVotes != 0 {
+		log.Printf("\tinvalid: %d, other versions: %d", a.InvalidVotes, a.OtherVersionVotes)
+	}
+
+	if votingEndHeight < a.EndHeight {
+		a.Projection = a.project(time.Now())
+	}
+
+	if cacheable {
+		cache.storeTally(a.ID, a.VoteVersion, lastBlockHash.String(), votingEndHeight,
+			a.VoteCounts, a.Progression)
 	}
 
 	return nil
@@ -185,6 +349,7 @@ func (a *Agenda) countVotes(ctx context.Context, dcrdClient *rpcclient.Client, v
 // agendasFromJSON parses the response from GetVoteInfo, and
 // uses the data to create a set of Agenda objects
 func agendasFromJSON(getVoteInfo types.GetVoteInfoResult) []Agenda {
+	catalog := currentAgendaCatalog.Load()
 	parsedAgendas := make([]Agenda, 0, len(getVoteInfo.Agendas))
 	for _, a := range getVoteInfo.Agendas {
 		voteChoices := make(map[string]VoteChoice)
@@ -196,30 +361,30 @@ func agendasFromJSON(getVoteInfo types.GetVoteInfoResult) []Agenda {
 			}
 			voteChoices[vote.ID] = vote
 		}
-		parsedAgendas = append(parsedAgendas, Agenda{
-			ID:          a.ID,
-			Status:      a.Status,
-			Title:       agendaTitles[a.ID],
-			Description: a.Description,
-			// #nosec: this method will not auto-escape HTML. Verify data is well formed.
-			LongDescription: template.HTML(longAgendaDescriptions[a.ID]),
+		agenda := Agenda{
+			ID:              a.ID,
+			Status:          a.Status,
+			Description:     a.Description,
 			Mask:            a.Mask,
 			VoteVersion:     getVoteInfo.VoteVersion,
 			QuorumThreshold: int64(getVoteInfo.Quorum),
 			VoteChoices:     voteChoices,
 			VoteCounts:      make(map[string]int64),
-		})
+		}
+		catalog.apply(&agenda)
+		parsedAgendas = append(parsedAgendas, agenda)
 	}
 	return parsedAgendas
 }
 
-func agendasForVersions(ctx context.Context, dcrdClient *rpcclient.Client, currentHeight int64, svis StakeVersionIntervals) ([]Agenda, error) {
+func agendasForVersions(ctx context.Context, chain chainSource, cache *voteCache, currentHeight int64, svis StakeVersionIntervals) ([]Agenda, error) {
 	var allAgendas []Agenda
+	blockVersions := blockVersionWindows(ctx, chain, cache, currentHeight)
 	for version := svis.MinVoteVersion; version <= svis.MaxVoteVersion; version++ {
 		// Retrieve Agendas for this voting period
-		getVoteInfo, err := dcrdClient.GetVoteInfo(ctx, version)
+		getVoteInfo, err := chain.GetVoteInfo(ctx, version)
 		if err != nil {
-			if strings.Contains(err.Error(), "unrecognized vote version") {
+			if isRPCError(err, dcrjson.ErrRPCInvalidParameter) {
 				continue
 			}
 			return nil, err
@@ -227,7 +392,10 @@ func agendasForVersions(ctx context.Context, dcrdClient *rpcclient.Client, curre
 		agendas := agendasFromJSON(*getVoteInfo)
 
 		// Check if upgrade to this version has occurred yet
-		upgradeOccurred, upgradeSVI := svis.GetStakeVersionUpgradeSVI(version)
+		upgradeOccurred, upgradeSVI, err := svis.GetStakeVersionUpgradeSVI(version, blockVersions)
+		if err != nil {
+			return nil, err
+		}
 
 		if !upgradeOccurred {
 			// Haven't upgraded to this stake version yet. Therefore
@@ -272,10 +440,11 @@ func agendasForVersions(ctx context.Context, dcrdClient *rpcclient.Client, curre
 		}
 
 		// Count votes and store totals within Agenda struct
-		for _, agenda := range agendas {
+		for i := range agendas {
+			agenda := &agendas[i]
 			log.Printf("Counting votes for %s between blocks %d-%d",
 				agenda.ID, votingStartHeight, votingEndHeight)
-			err = agenda.countVotes(ctx, dcrdClient, votingStartHeight, votingEndHeight)
+			err = agenda.countVotes(ctx, chain, cache, votingStartHeight, votingEndHeight, currentHeight)
 			if err != nil {
 				log.Printf("Error counting agenda %q votes: %v", agenda.ID, err)
 				return nil, err
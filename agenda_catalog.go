@@ -0,0 +1,200 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"sync/atomic"
+)
+
+// builtinAgendaCatalog is the metadata of every agenda known when this
+// version of dcrvotingweb was released.
+//
+//go:embed agendas.json
+var builtinAgendaCatalog []byte
+
+// currentAgendaCatalog holds the metadata of the agendas, which dcrd does not
+// provide, keyed by agenda ID.
+var currentAgendaCatalog atomic.Pointer[agendaCatalog]
+
+func init() {
+	catalog, err := parseAgendaCatalog(builtinAgendaCatalog)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in agenda catalog: %v", err))
+	}
+	currentAgendaCatalog.Store(catalog)
+}
+
+// agendaCatalog is the metadata of the agendas, as read from a catalog file.
+type agendaCatalog struct {
+	Agendas map[string]agendaMetadata `json:"agendas"`
+}
+
+// agendaMetadata describes a single agenda.
+type agendaMetadata struct {
+	// Title is the main heading for the agenda card.
+	Title string `json:"title"`
+	// LongDescription is a more verbose description, which may contain
+	// HTML.
+	LongDescription string `json:"long_description"`
+	// DCPs are the numbers of the Decred Change Proposals which specify the
+	// agenda.
+	DCPs []DCP `json:"dcps"`
+	// Proposals are links to the Politeia proposals for the agenda.
+	Proposals []ProposalLink `json:"proposals"`
+	// Choices are explanations of the vote choices, keyed by choice ID.
+	Choices map[string]string `json:"choices"`
+}
+
+// DCP is the number of a Decred Change Proposal.
+type DCP int
+
+// String returns the name of the DCP, e.g. DCP0013.
+func (d DCP) String() string {
+	return fmt.Sprintf("DCP%04d", int(d))
+}
+
+// URL returns the address of the specification of the DCP.
+func (d DCP) URL() string {
+	return fmt.Sprintf("https://github.com/decred/dcps/blob/master/dcp-%04d/dcp-%04d.mediawiki",
+		int(d), int(d))
+}
+
+// ProposalLink is a link to a Politeia proposal.
+type ProposalLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// validate returns an error describing the first problem found with the
+// metadata.
+func (m *agendaMetadata) validate() error {
+	if m.Title == "" {
+		return errors.New("missing title")
+	}
+	for _, dcp := range m.DCPs {
+		if dcp < 1 || dcp > 9999 {
+			return fmt.Errorf("invalid DCP number %d", dcp)
+		}
+	}
+	for _, p := range m.Proposals {
+		if p.Title == "" {
+			return fmt.Errorf("proposal %q is missing a title", p.URL)
+		}
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("proposal %q has invalid URL %q", p.Title, p.URL)
+		}
+	}
+	for id, explanation := range m.Choices {
+		if id == "" || explanation == "" {
+			return fmt.Errorf("invalid explanation %q of choice %q", explanation, id)
+		}
+	}
+	return nil
+}
+
+// parseAgendaCatalog parses and validates an agenda catalog.
+func parseAgendaCatalog(b []byte) (*agendaCatalog, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var catalog agendaCatalog
+	if err := dec.Decode(&catalog); err != nil {
+		return nil, err
+	}
+	for id, m := range catalog.Agendas {
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("agenda %q: %w", id, err)
+		}
+	}
+	return &catalog, nil
+}
+
+// loadAgendaCatalog returns the built-in agenda catalog with the agendas from
+// the catalog file at path added or replaced.  A missing file is not an error.
+func loadAgendaCatalog(path string) (*agendaCatalog, error) {
+	catalog, err := parseAgendaCatalog(builtinAgendaCatalog)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return catalog, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agenda catalog: %w", err)
+	}
+	file, err := parseAgendaCatalog(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agenda catalog %s: %w", path, err)
+	}
+	for id, m := range file.Agendas {
+		catalog.Agendas[id] = m
+	}
+
+	log.Printf("Loaded metadata of %d agendas from %s", len(file.Agendas), path)
+
+	return catalog, nil
+}
+
+// apply sets the metadata of an agenda from the catalog.  Agendas missing from
+// the catalog are titled with the description provided by dcrd.
+func (c *agendaCatalog) apply(a *Agenda) {
+	m, ok := c.Agendas[a.ID]
+	if !ok {
+		m.Title = a.Description
+	}
+	a.Title = m.Title
+	// #nosec: this method will not auto-escape HTML. Verify data is well formed.
+	a.LongDescription = template.HTML(m.LongDescription)
+	a.DCPs = slices.Clone(m.DCPs)
+	a.Proposals = slices.Clone(m.Proposals)
+
+	// The choices may be shared with a published snapshot, so they are
+	// replaced rather than modified.
+	choices := make(map[string]VoteChoice, len(a.VoteChoices))
+	for id, choice := range a.VoteChoices {
+		choice.Explanation = m.Choices[id]
+		choices[id] = choice
+	}
+	a.VoteChoices = choices
+}
+
+// reloadAgendaCatalog loads the catalog file at path and passes it to the
+// updater u, which publishes the current voting information with the new
+// metadata once it is not busy.  An invalid catalog file is logged and
+// ignored.
+func reloadAgendaCatalog(path string, u *updater) {
+	catalog, err := loadAgendaCatalog(path)
+	if err != nil {
+		log.Printf("Failed to reload agenda catalog: %v", err)
+		return
+	}
+	u.reloadCatalog(catalog)
+}
+
+// See reloadsig*.go for an exported method
+func reloadAgendaCatalogSig(sig os.Signal, path string, u *updater) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, sig)
+
+	go func() {
+		for range sigChan {
+			reloadAgendaCatalog(path, u)
+		}
+	}()
+}
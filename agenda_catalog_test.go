@@ -0,0 +1,236 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAgendaCatalog writes an agenda catalog file to a temporary directory
+// and returns its path.
+func writeAgendaCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), agendaCatalogFilename)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAgendaCatalog(t *testing.T) {
+	// A missing file results in only the built-in metadata.
+	catalog, err := loadAgendaCatalog(filepath.Join(t.TempDir(), agendaCatalogFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := catalog.Agendas["maxtreasuryspend"]
+	if m.Title != "Change Maximum Treasury Expenditure Policy" ||
+		len(m.DCPs) != 1 || m.DCPs[0].String() != "DCP0013" {
+		t.Fatalf("unexpected built-in metadata %+v", m)
+	}
+
+	// Agendas in the file are added to the built-in metadata, or replace
+	// it.
+	path := writeAgendaCatalog(t, `{"agendas": {
+		"newagenda": {"title": "New Agenda", "dcps": [14]},
+		"maxtreasuryspend": {"title": "Replaced", "choices": {"yes": "Limit spending."}}
+	}}`)
+	catalog, err = loadAgendaCatalog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := catalog.Agendas["newagenda"]; m.Title != "New Agenda" || m.DCPs[0] != 14 {
+		t.Errorf("unexpected added metadata %+v", m)
+	}
+	if m := catalog.Agendas["maxtreasuryspend"]; m.Title != "Replaced" ||
+		m.LongDescription != "" || m.Choices["yes"] != "Limit spending." {
+		t.Errorf("unexpected replaced metadata %+v", m)
+	}
+	if m := catalog.Agendas["blake3pow"]; m.Title != "Change PoW to BLAKE3 and ASERT" {
+		t.Errorf("unexpected built-in metadata %+v", m)
+	}
+}
+
+func TestLoadAgendaCatalogInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{{
+		name:    "malformed",
+		content: `{"agendas": {`,
+		wantErr: "unexpected EOF",
+	}, {
+		name:    "unknown field",
+		content: `{"agendas": {"a": {"title": "A", "titel": "A"}}}`,
+		wantErr: `unknown field "titel"`,
+	}, {
+		name:    "missing title",
+		content: `{"agendas": {"a": {"long_description": "A"}}}`,
+		wantErr: "missing title",
+	}, {
+		name:    "invalid DCP",
+		content: `{"agendas": {"a": {"title": "A", "dcps": [0]}}}`,
+		wantErr: "invalid DCP number 0",
+	}, {
+		name:    "invalid proposal URL",
+		content: `{"agendas": {"a": {"title": "A", "proposals": [{"title": "P", "url": "javascript:alert(1)"}]}}}`,
+		wantErr: "invalid URL",
+	}, {
+		name:    "empty choice explanation",
+		content: `{"agendas": {"a": {"title": "A", "choices": {"yes": ""}}}}`,
+		wantErr: "invalid explanation",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadAgendaCatalog(writeAgendaCatalog(t, test.content))
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("error %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAgendaCatalogApply(t *testing.T) {
+	catalog, err := parseAgendaCatalog([]byte(`{"agendas": {
+		"known": {"title": "Known", "choices": {"yes": "Do it."}}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	choices := map[string]VoteChoice{"yes": {ID: "yes"}, "no": {ID: "no"}}
+	known := Agenda{ID: "known", Description: "known agenda", VoteChoices: choices}
+	catalog.apply(&known)
+	if known.Title != "Known" || known.VoteChoices["yes"].Explanation != "Do it." ||
+		known.VoteChoices["no"].Explanation != "" {
+		t.Errorf("unexpected metadata %+v", known)
+	}
+	if choices["yes"].Explanation != "" {
+		t.Errorf("original vote choices were modified")
+	}
+
+	unknown := Agenda{ID: "unknown", Description: "unknown agenda"}
+	catalog.apply(&unknown)
+	if unknown.Title != "unknown agenda" || unknown.LongDescription != "" {
+		t.Errorf("unexpected metadata %+v", unknown)
+	}
+}
+
+// TestReloadAgendaCatalog ensures that reloading the agenda catalog updates
+// the metadata of the agendas being served, and notifies clients of it, both
+// while dcrd is connected and while it is not, and that an invalid catalog is
+// ignored.
+func TestReloadAgendaCatalog(t *testing.T) {
+	prevCatalog := currentAgendaCatalog.Load()
+	t.Cleanup(func() { currentAgendaCatalog.Store(prevCatalog) })
+
+	h := newTestHarness(t, upgradingChain())
+	h.assertPage("Change Maximum Treasury Expenditure Policy", "DCP0013")
+	s := h.openEventStream("")
+	s.next()
+
+	reloaded := func(n uint64) {
+		t.Helper()
+		waitForSnapshot(t, func(t *templateFields) bool { return t.CatalogReloads == n })
+		if e := s.next(); e.UpdateID != templateInformation.Load().UpdateID() {
+			t.Fatalf("event %+v, want the reloaded voting information", e)
+		}
+	}
+
+	path := writeAgendaCatalog(t, `{"agendas": {"maxtreasuryspend": {
+		"title": "Limit Treasury Spending",
+		"choices": {"yes": "Limit spending to 4% per month."}
+	}}}`)
+	reloadAgendaCatalog(path, h.updater)
+	reloaded(1)
+	h.assertPage("Limit Treasury Spending", "Limit spending to 4% per month.")
+
+	var agenda apiAgenda
+	h.getJSON("/api/v1/agendas/maxtreasuryspend", &agenda)
+	if agenda.Title != "Limit Treasury Spending" || len(agenda.DCPs) != 0 {
+		t.Errorf("unexpected agenda %+v", agenda)
+	}
+
+	// The metadata is retained by later updates.
+	h.mineTo(310, 12, 11, votes(5, 12, 0))
+	s.next()
+	h.assertPage("Limit Treasury Spending")
+
+	// The catalog is also reloaded while dcrd is unavailable.
+	h.dcrd.setOnline(false)
+	s.next()
+	path = writeAgendaCatalog(t, `{"agendas": {"maxtreasuryspend": {
+		"title": "Cap Treasury Spending"
+	}}}`)
+	reloadAgendaCatalog(path, h.updater)
+	reloaded(2)
+	h.assertPage("Cap Treasury Spending")
+
+	if err := os.WriteFile(path, []byte(`{"agendas": {`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloadAgendaCatalog(path, h.updater)
+	s.assertNoEvent()
+	h.assertPage("Cap Treasury Spending")
+}
+
+// TestReloadAgendaCatalogBusy ensures that reloading the agenda catalog does
+// not wait for an update which is in progress, and that only the newest of the
+// catalogs reloaded meanwhile is applied once it has finished.
+func TestReloadAgendaCatalogBusy(t *testing.T) {
+	prevCatalog := currentAgendaCatalog.Load()
+	t.Cleanup(func() { currentAgendaCatalog.Store(prevCatalog) })
+
+	h := newTestHarness(t, upgradingChain())
+
+	// Hold the update for the next block while it requests the stake
+	// version intervals.
+	release := h.dcrd.stall("getstakeversioninfo")
+	requested := len(h.dcrd.requestsOf("getstakeversioninfo"))
+	h.chain.mineTo(301, 12, 11, votes(5, 12, 0))
+	h.dcrd.notifyConnected()
+	deadline := time.Now().Add(10 * time.Second)
+	for len(h.dcrd.requestsOf("getstakeversioninfo")) == requested {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the update")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	paths := []string{
+		writeAgendaCatalog(t, `{"agendas": {"maxtreasuryspend": {
+			"title": "Limit Treasury Spending"
+		}}}`),
+		writeAgendaCatalog(t, `{"agendas": {"maxtreasuryspend": {
+			"title": "Cap Treasury Spending"
+		}}}`),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, path := range paths {
+			reloadAgendaCatalog(path, h.updater)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("reloading the agenda catalog waited for the update")
+	}
+
+	release()
+	h.waitForHeight(301)
+	waitForSnapshot(t, func(t *templateFields) bool { return t.CatalogReloads > 0 })
+	if n := templateInformation.Load().CatalogReloads; n != 1 {
+		t.Errorf("%d catalogs applied, want 1", n)
+	}
+	h.assertPage("Cap Treasury Spending")
+}
@@ -0,0 +1,912 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+)
+
+// apiVersion is the version of the JSON API served under /api/v1/. Fields may
+// be added to the response types below without bumping the version, but
+// existing fields will never be renamed, retyped or removed.
+const apiVersion = 1
+
+// apiStatus is the response body of /api/v1/status.
+type apiStatus struct {
+	APIVersion       int    `json:"api_version"`
+	Network          string `json:"network"`
+	BlockHeight      int64  `json:"block_height"`
+	BlockExplorerURL string `json:"block_explorer_url"`
+	// Phase is one of "upgrading", "voting", "pending_activation" or
+	// "rules_activated".
+	Phase             string         `json:"phase"`
+	IsUpgrading       bool           `json:"is_upgrading"`
+	PendingActivation bool           `json:"pending_activation"`
+	RulesActivated    bool           `json:"rules_activated"`
+	PoW               apiPoWSummary  `json:"pow"`
+	PoS               apiPoSSummary  `json:"pos"`
+	Agendas           []apiAgendaRef `json:"agendas"`
+	// Reorgs is the number of chain reorganizations seen since startup.
+	Reorgs uint64 `json:"reorg_count"`
+	// SkippedUpdates is the number of block notifications which were
+	// coalesced into the update for a later block.
+	SkippedUpdates uint64 `json:"skipped_updates"`
+	// BackendAvailable is whether dcrd is currently connected.  When it is
+	// false, BackendError describes why.
+	BackendAvailable bool   `json:"backend_available"`
+	BackendError     string `json:"backend_error,omitempty"`
+	// LastUpdate is when the voting information was last updated, or null
+	// if it has not been computed yet.
+	LastUpdate         *time.Time `json:"last_update"`
+	SecondsSinceUpdate *int64     `json:"seconds_since_update"`
+}
+
+// apiPoWSummary is the block version upgrade progress included in the status
+// summary.
+type apiPoWSummary struct {
+	CurrentVersion   int32   `json:"current_version"`
+	NextVersion      int32   `json:"next_version"`
+	NextVersionShare float64 `json:"next_version_percentage"`
+	ThresholdPercent int     `json:"threshold_percentage"`
+	UpgradeComplete  bool    `json:"upgrade_complete"`
+}
+
+// apiPoSSummary is the stake version upgrade progress included in the status
+// summary.
+type apiPoSSummary struct {
+	CurrentVersion     uint32  `json:"current_version"`
+	MostPopularVersion uint32  `json:"most_popular_version"`
+	MostPopularShare   float64 `json:"most_popular_percentage"`
+	ThresholdPercent   float64 `json:"threshold_percentage"`
+	UpgradeComplete    bool    `json:"upgrade_complete"`
+}
+
+// apiAgendaRef is a short reference to an agenda included in the status
+// summary.
+type apiAgendaRef struct {
+	ID          string `json:"id"`
+	VoteVersion uint32 `json:"vote_version"`
+	Status      string `json:"status"`
+}
+
+// apiPoW is the response body of /api/v1/pow.
+type apiPoW struct {
+	WindowLength     int64   `json:"window_length"`
+	ThresholdPercent int     `json:"threshold_percentage"`
+	CurrentVersion   int32   `json:"current_version"`
+	NextVersion      int32   `json:"next_version"`
+	NextVersionShare float64 `json:"next_version_percentage"`
+	UpgradeComplete  bool    `json:"upgrade_complete"`
+	// Heights are the block heights of the rolling window samples, ordered
+	// oldest to newest.  Each entry of Versions[].WindowCounts corresponds to
+	// the height at the same index.
+	Heights  []int64            `json:"heights"`
+	Versions []apiBlockVersions `json:"versions"`
+}
+
+// apiBlockVersions is the number of blocks of a single block version found in
+// the rolling window at each sampled height.
+type apiBlockVersions struct {
+	Version      int32 `json:"version"`
+	WindowCounts []int `json:"window_counts"`
+}
+
+// apiStakeVersionIntervals is the response body of
+// /api/v1/stakeversionintervals.
+type apiStakeVersionIntervals struct {
+	WindowLength       int64   `json:"window_length"`
+	ThresholdPercent   float64 `json:"threshold_percentage"`
+	CurrentVersion     uint32  `json:"current_version"`
+	MostPopularVersion uint32  `json:"most_popular_version"`
+	MostPopularShare   float64 `json:"most_popular_percentage"`
+	CurrentStartHeight int64   `json:"current_start_height"`
+	CurrentEndHeight   int64   `json:"current_end_height"`
+	TimeRemaining      string  `json:"time_remaining"`
+	UpgradeComplete    bool    `json:"upgrade_complete"`
+	// UpgradeInterval is the interval in which the stake version upgrade
+	// took place, or null if it has not happened.
+	UpgradeInterval *apiInterval `json:"upgrade_interval"`
+	// Intervals are the most recent intervals, ordered newest to oldest.
+	// The first entry is the current, incomplete, interval.
+	Intervals []apiInterval `json:"intervals"`
+}
+
+// apiInterval describes a single stake version interval.  EndHeight is
+// exclusive for complete intervals and the current height for the current
+// interval, matching dcrd's getstakeversioninfo.
+type apiInterval struct {
+	StartHeight  int64             `json:"start_height"`
+	EndHeight    int64             `json:"end_height"`
+	PoSVersions  []apiVersionCount `json:"pos_versions"`
+	VoteVersions []apiVersionCount `json:"vote_versions"`
+}
+
+// apiVersionCount is the number of blocks or votes seen with a version.
+type apiVersionCount struct {
+	Version uint32 `json:"version"`
+	Count   uint32 `json:"count"`
+}
+
+// apiAgenda is an element of the /api/v1/agendas response body, and the
+// response body of /api/v1/agendas/{id}.
+type apiAgenda struct {
+	ID              string `json:"id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	LongDescription string `json:"long_description"`
+	// DCPs are the names of the Decred Change Proposals specifying the
+	// agenda, e.g. "DCP0013".
+	DCPs      []string          `json:"dcps"`
+	Proposals []apiProposalLink `json:"proposals"`
+	// Status is one of "defined", "started", "lockedin", "active" or
+	// "failed".
+	Status          string `json:"status"`
+	VoteVersion     uint32 `json:"vote_version"`
+	Mask            uint16 `json:"mask"`
+	QuorumThreshold int64  `json:"quorum_threshold"`
+	// StartHeight and EndHeight are zero until the stake version upgrade for
+	// VoteVersion has completed and the voting window is known.
+	StartHeight int64 `json:"start_height"`
+	EndHeight   int64 `json:"end_height"`
+	// LockedInHeight and ActivationHeight are -1 until the agenda has been
+	// locked in.
+	LockedInHeight       int64       `json:"locked_in_height"`
+	ActivationHeight     int64       `json:"activation_height"`
+	TotalVotes           int64       `json:"total_votes"`
+	TotalNonAbstainVotes int64       `json:"total_non_abstain_votes"`
+	QuorumMet            bool        `json:"quorum_met"`
+	ApprovalRating       float64     `json:"approval_percentage"`
+	Choices              []apiChoice `json:"choices"`
+	// InvalidVotes are the votes of VoteVersion whose bits match no choice,
+	// and OtherVersionVotes the votes of other vote versions cast during
+	// the voting window.  Neither are counted in the choices.
+	InvalidVotes      int64 `json:"invalid_votes"`
+	OtherVersionVotes int64 `json:"other_version_votes"`
+	// Projection is only included while the voting window is in
+	// progress.
+	Projection *apiProjection `json:"projection,omitempty"`
+}
+
+// apiProjection is the extrapolation of the recent votes of an agenda to the
+// end of its voting window.
+type apiProjection struct {
+	Height          int64   `json:"height"`
+	BlocksRemaining int64   `json:"blocks_remaining"`
+	YesPerBlock     float64 `json:"yes_per_block"`
+	NoPerBlock      float64 `json:"no_per_block"`
+	// QuorumHeight is -1 if the quorum is not projected to be met before
+	// the voting window ends.  QuorumTime is only included when the quorum
+	// has not been met yet but is projected to be.
+	QuorumHeight           int64      `json:"quorum_height"`
+	QuorumTime             *time.Time `json:"quorum_time,omitempty"`
+	FinalApproval          float64    `json:"final_approval_percentage"`
+	MinYesPercentageToPass float64    `json:"min_yes_percentage_to_pass"`
+	MinNoPercentageToFail  float64    `json:"min_no_percentage_to_fail"`
+}
+
+// apiProposalLink is a link to a Politeia proposal for an agenda.
+type apiProposalLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// apiChoice is a single vote choice of an agenda along with its tally.
+type apiChoice struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	Explanation string  `json:"explanation,omitempty"`
+	Bits        uint16  `json:"bits"`
+	Votes       int64   `json:"votes"`
+	Percentage  float64 `json:"percentage"`
+}
+
+// apiVoteProgression is the response body of
+// /api/v1/agendas/{id}/progression.
+type apiVoteProgression struct {
+	ID          string `json:"id"`
+	VoteVersion uint32 `json:"vote_version"`
+	// BucketSize is the number of blocks between points.
+	BucketSize int64 `json:"bucket_size"`
+	// Heights are the heights of the final block counted at each point,
+	// oldest to newest.  Each entry of the other series corresponds to the
+	// height at the same index.
+	Heights             []int64                `json:"heights"`
+	Choices             []apiChoiceProgression `json:"choices"`
+	ApprovalPercentages []float64              `json:"approval_percentages"`
+	QuorumPercentages   []float64              `json:"quorum_percentages"`
+	InvalidCounts       []int64                `json:"invalid_counts"`
+	OtherVersionCounts  []int64                `json:"other_version_counts"`
+}
+
+// apiChoiceProgression is the cumulative number of votes for a single vote
+// choice at each point of a vote progression.
+type apiChoiceProgression struct {
+	ID     string  `json:"id"`
+	Counts []int64 `json:"counts"`
+}
+
+// apiTSpend is an element of the /api/v1/tspends response body.
+type apiTSpend struct {
+	Hash string `json:"hash"`
+	// Amount is in atoms.
+	Amount           int64   `json:"amount"`
+	Expiry           int64   `json:"expiry"`
+	VoteStart        int64   `json:"vote_start"`
+	VoteEnd          int64   `json:"vote_end"`
+	Yes              int64   `json:"yes"`
+	No               int64   `json:"no"`
+	QuorumThreshold  int64   `json:"quorum_threshold"`
+	QuorumMet        bool    `json:"quorum_met"`
+	ApprovalRating   float64 `json:"approval_percentage"`
+	RequiredApproval float64 `json:"required_approval_percentage"`
+	Status           string  `json:"status"`
+	// MinedHeight is -1 unless the tspend has been mined.
+	MinedHeight int64                `json:"mined_height"`
+	Projection  *apiTSpendProjection `json:"projection,omitempty"`
+}
+
+// apiTSpendProjection is the extrapolation of the votes cast on a tspend to the
+// end of its voting window.
+type apiTSpendProjection struct {
+	Height          int64   `json:"height"`
+	BlocksRemaining int64   `json:"blocks_remaining"`
+	YesPerBlock     float64 `json:"yes_per_block"`
+	NoPerBlock      float64 `json:"no_per_block"`
+	FinalApproval   float64 `json:"final_approval_percentage"`
+	QuorumProjected bool    `json:"quorum_projected"`
+	Approved        bool    `json:"projected_approved"`
+	CanPass         bool    `json:"can_pass"`
+}
+
+// apiBlockVotes is the response body of /api/v1/blocks/{block}/votes.
+type apiBlockVotes struct {
+	Height       int64          `json:"height"`
+	Hash         string         `json:"hash"`
+	StakeVersion uint32         `json:"stake_version"`
+	Votes        []apiBlockVote `json:"votes"`
+	// Tallies are the votes counted in the block for every agenda whose
+	// voting window includes it.
+	Tallies []apiBlockTally `json:"tallies"`
+}
+
+// apiBlockTally is the votes cast in a single block on an agenda.
+type apiBlockTally struct {
+	AgendaID          string           `json:"agenda_id"`
+	VoteVersion       uint32           `json:"vote_version"`
+	Counts            map[string]int64 `json:"counts"`
+	InvalidVotes      int64            `json:"invalid_votes"`
+	OtherVersionVotes int64            `json:"other_version_votes"`
+}
+
+// apiBlockVote is a single vote of a block, decoded against the agendas of its
+// vote version.
+type apiBlockVote struct {
+	Version        uint32             `json:"version"`
+	Bits           uint16             `json:"bits"`
+	ApprovesParent bool               `json:"approves_parent"`
+	UnknownVersion bool               `json:"unknown_version"`
+	Choices        []apiDecodedChoice `json:"choices"`
+	UndefinedBits  uint16             `json:"undefined_bits"`
+}
+
+// apiDecodedChoice is the choice a vote makes on a single agenda.  Choice is
+// empty when the bits match no choice of the agenda.
+type apiDecodedChoice struct {
+	AgendaID string `json:"agenda_id"`
+	Mask     uint16 `json:"mask"`
+	Bits     uint16 `json:"bits"`
+	Choice   string `json:"choice"`
+	Valid    bool   `json:"valid"`
+}
+
+// apiParticipationSummary is the response body of /api/v1/participation.
+type apiParticipationSummary struct {
+	TicketsPerBlock uint16 `json:"tickets_per_block"`
+	// StakeVersionIntervals are the same intervals as
+	// /api/v1/stakeversionintervals, oldest first.
+	StakeVersionIntervals []apiParticipation `json:"stake_version_intervals"`
+	// VotingWindows are the rule change intervals agendas have been voted
+	// on in, oldest first.
+	VotingWindows []apiParticipation `json:"voting_windows"`
+	// CurrentIntervalVoters is the number of votes in each block of the
+	// current stake version interval, starting at its first block.
+	CurrentIntervalStartHeight int64   `json:"current_interval_start_height"`
+	CurrentIntervalVoters      []int64 `json:"current_interval_voters"`
+}
+
+// apiParticipation is the number of votes cast in a range of blocks, compared
+// with the number which could have been cast.  EndHeight is inclusive.
+type apiParticipation struct {
+	StartHeight int64   `json:"start_height"`
+	EndHeight   int64   `json:"end_height"`
+	Blocks      int64   `json:"blocks"`
+	Slots       int64   `json:"slots"`
+	Votes       int64   `json:"votes"`
+	MissedVotes int64   `json:"missed_votes"`
+	Percentage  float64 `json:"participation_percentage"`
+}
+
+// apiError is the response body of any API request which fails.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// finite replaces the NaN and infinite values which result from dividing by
+// zero vote counts with zero, as they can not be encoded as JSON.
+func finite(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return f
+}
+
+// errNoData is the response body of API requests which are received before
+// the voting information has been computed.
+var errNoData = apiError{Error: "voting information is not available yet"}
+
+// phase describes the current voting phase in the same way as the header of
+// the home page.
+func (t *templateFields) phase() string {
+	switch {
+	case t.IsUpgrading:
+		return "upgrading"
+	case t.PendingActivation:
+		return "pending_activation"
+	case t.RulesActivated:
+		return "rules_activated"
+	default:
+		return "voting"
+	}
+}
+
+func newAPIVersionCounts(vcs []types.VersionCount) []apiVersionCount {
+	counts := make([]apiVersionCount, 0, len(vcs))
+	for _, vc := range vcs {
+		counts = append(counts, apiVersionCount{Version: vc.Version, Count: vc.Count})
+	}
+	return counts
+}
+
+func newAPIInterval(svi *types.VersionInterval) apiInterval {
+	return apiInterval{
+		StartHeight:  svi.StartHeight,
+		EndHeight:    svi.EndHeight,
+		PoSVersions:  newAPIVersionCounts(svi.PoSVersions),
+		VoteVersions: newAPIVersionCounts(svi.VoteVersions),
+	}
+}
+
+func newAPIAgenda(a *Agenda) apiAgenda {
+	choices := make([]apiChoice, 0, len(a.VoteChoices))
+	for _, c := range a.VoteChoices {
+		choices = append(choices, apiChoice{
+			ID:          c.ID,
+			Description: c.Description,
+			Explanation: c.Explanation,
+			Bits:        c.Bits,
+			Votes:       a.VoteCounts[c.ID],
+			Percentage:  finite(a.VotePercent(c.ID)),
+		})
+	}
+	// Order choices by their bits so the output is stable. By convention this
+	// puts abstain first.
+	sort.Slice(choices, func(i, j int) bool {
+		return choices[i].Bits < choices[j].Bits
+	})
+
+	dcps := make([]string, 0, len(a.DCPs))
+	for _, dcp := range a.DCPs {
+		dcps = append(dcps, dcp.String())
+	}
+	proposals := make([]apiProposalLink, 0, len(a.Proposals))
+	for _, p := range a.Proposals {
+		proposals = append(proposals, apiProposalLink{Title: p.Title, URL: p.URL})
+	}
+
+	return apiAgenda{
+		ID:                   a.ID,
+		Title:                a.Title,
+		Description:          a.Description,
+		LongDescription:      string(a.LongDescription),
+		DCPs:                 dcps,
+		Proposals:            proposals,
+		Status:               a.Status,
+		VoteVersion:          a.VoteVersion,
+		Mask:                 a.Mask,
+		QuorumThreshold:      a.QuorumThreshold,
+		StartHeight:          a.StartHeight,
+		EndHeight:            a.EndHeight,
+		LockedInHeight:       a.BlockLockedIn(),
+		ActivationHeight:     a.ActivationBlock(),
+		TotalVotes:           a.TotalVotes(),
+		TotalNonAbstainVotes: a.TotalNonAbstainVotes(),
+		QuorumMet:            a.QuorumMet(),
+		ApprovalRating:       finite(a.ApprovalRating()),
+		Choices:              choices,
+		InvalidVotes:         a.InvalidVotes,
+		OtherVersionVotes:    a.OtherVersionVotes,
+		Projection:           newAPIProjection(a.Projection),
+	}
+}
+
+// newAPIProjection converts a vote projection to its API representation.
+func newAPIProjection(p *VoteProjection) *apiProjection {
+	if p == nil {
+		return nil
+	}
+	proj := &apiProjection{
+		Height:                 p.Height,
+		BlocksRemaining:        p.BlocksRemaining,
+		YesPerBlock:            p.YesPerBlock,
+		NoPerBlock:             p.NoPerBlock,
+		QuorumHeight:           p.QuorumHeight,
+		FinalApproval:          p.FinalApproval,
+		MinYesPercentageToPass: p.MinYesShareToPass,
+		MinNoPercentageToFail:  p.MinNoShareToFail,
+	}
+	if !p.QuorumTime.IsZero() {
+		quorumTime := p.QuorumTime.UTC()
+		proj.QuorumTime = &quorumTime
+	}
+	return proj
+}
+
+// jsonContentType is the Content-Type header of every JSON API response.
+const jsonContentType = "application/json; charset=utf-8"
+
+// writeJSON encodes v as the JSON response body with the provided status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Failed to write JSON response: %v", err)
+	}
+}
+
+// writeSnapshotJSON serves the JSON encoding of the value returned by build,
+// which must only depend on the snapshot t.  It is encoded once per snapshot
+// and cached under key, so subsequent requests are served the same body, and
+// ETag, until the voting information is updated.
+func (td *WebUI) writeSnapshotJSON(w http.ResponseWriter, r *http.Request,
+	t *templateFields, key string, build func() any) {
+
+	responses := td.responses.forSnapshot(t, td.TemplateData.Load(), td.templ.Load())
+	resp, err := responses.response(key, func() (*cachedResponse, error) {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(build()); err != nil {
+			return nil, err
+		}
+		return newCachedResponse(jsonContentType, responses.modTime, buf.Bytes()), nil
+	})
+	if err != nil {
+		log.Printf("Failed to write JSON response: %v", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "internal error"})
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	resp.serve(w, r, "no-cache")
+}
+
+// apiStatus serves a summary of the current voting state.  Unlike the other
+// endpoints it is not cached, as seconds_since_update changes every second.
+func (td *WebUI) apiStatus(w http.ResponseWriter, _ *http.Request) {
+	t := td.TemplateData.Load()
+
+	agendas := make([]apiAgendaRef, 0, len(t.Agendas))
+	for _, a := range t.Agendas {
+		agendas = append(agendas, apiAgendaRef{
+			ID:          a.ID,
+			VoteVersion: a.VoteVersion,
+			Status:      a.Status,
+		})
+	}
+
+	status := apiStatus{
+		APIVersion:        apiVersion,
+		Network:           t.Network,
+		BlockHeight:       t.BlockHeight,
+		BlockExplorerURL:  t.BlockExplorerURL,
+		Phase:             t.phase(),
+		IsUpgrading:       t.IsUpgrading,
+		PendingActivation: t.PendingActivation,
+		RulesActivated:    t.RulesActivated,
+		Reorgs:            t.Reorgs,
+		SkippedUpdates:    t.SkippedUpdates,
+		BackendAvailable:  t.BackendAvailable,
+		BackendError:      t.BackendError,
+		PoW: apiPoWSummary{
+			CurrentVersion:   t.BlockVersionCurrent,
+			NextVersion:      t.BlockVersionNext,
+			NextVersionShare: finite(t.BlockVersionNextPercentage),
+			ThresholdPercent: t.BlockVersionRejectThreshold,
+			UpgradeComplete:  t.BlockVersionSuccess,
+		},
+		PoS: apiPoSSummary{
+			CurrentVersion:     t.StakeVersionCurrent,
+			MostPopularVersion: t.StakeVersionMostPopular,
+			MostPopularShare:   finite(t.StakeVersionMostPopularPercentage),
+			ThresholdPercent:   t.StakeVersionThreshold,
+			UpgradeComplete:    t.PosUpgrade.Completed,
+		},
+		Agendas: agendas,
+	}
+	if t.HasData() {
+		lastUpdate := t.LastUpdate.UTC()
+		secondsSince := int64(time.Since(t.LastUpdate).Seconds())
+		status.LastUpdate = &lastUpdate
+		status.SecondsSinceUpdate = &secondsSince
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// apiPoW serves the block versions seen in the PoW rolling window.
+func (td *WebUI) apiPoW(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		writeJSON(w, http.StatusServiceUnavailable, errNoData)
+		return
+	}
+
+	td.writeSnapshotJSON(w, r, t, "pow", func() any {
+		return newAPIPoW(t)
+	})
+}
+
+// newAPIPoW returns the block versions seen in the PoW rolling window of t.
+func newAPIPoW(t *templateFields) *apiPoW {
+	versions := make([]apiBlockVersions, 0, len(t.BlockVersions))
+	for v, bv := range t.BlockVersions {
+		versions = append(versions, apiBlockVersions{
+			Version:      v,
+			WindowCounts: bv.RollingWindowLookBacks,
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+
+	heights := t.BlockVersionsHeights
+	if heights == nil {
+		heights = []int64{}
+	}
+
+	return &apiPoW{
+		WindowLength:     t.BlockVersionWindowLength,
+		ThresholdPercent: t.BlockVersionRejectThreshold,
+		CurrentVersion:   t.BlockVersionCurrent,
+		NextVersion:      t.BlockVersionNext,
+		NextVersionShare: finite(t.BlockVersionNextPercentage),
+		UpgradeComplete:  t.BlockVersionSuccess,
+		Heights:          heights,
+		Versions:         versions,
+	}
+}
+
+// apiStakeVersionIntervals serves the vote versions seen in the most recent
+// stake version intervals.
+func (td *WebUI) apiStakeVersionIntervals(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		writeJSON(w, http.StatusServiceUnavailable, errNoData)
+		return
+	}
+
+	td.writeSnapshotJSON(w, r, t, "stakeversionintervals", func() any {
+		return newAPIStakeVersionIntervals(t)
+	})
+}
+
+// newAPIStakeVersionIntervals returns the vote versions seen in the most
+// recent stake version intervals of t.
+func newAPIStakeVersionIntervals(t *templateFields) *apiStakeVersionIntervals {
+	intervals := make([]apiInterval, 0, len(t.StakeVersionsIntervals))
+	for i := range t.StakeVersionsIntervals {
+		intervals = append(intervals, newAPIInterval(&t.StakeVersionsIntervals[i]))
+	}
+
+	var upgradeInterval *apiInterval
+	if t.PosUpgrade.Completed {
+		svi := newAPIInterval(&t.PosUpgrade.UpgradeInterval)
+		upgradeInterval = &svi
+	}
+
+	return &apiStakeVersionIntervals{
+		WindowLength:       t.StakeVersionWindowLength,
+		ThresholdPercent:   t.StakeVersionThreshold,
+		CurrentVersion:     t.StakeVersionCurrent,
+		MostPopularVersion: t.StakeVersionMostPopular,
+		MostPopularShare:   finite(t.StakeVersionMostPopularPercentage),
+		CurrentStartHeight: t.CurrentSVIStartHeight,
+		CurrentEndHeight:   t.CurrentSVIEndHeight,
+		TimeRemaining:      t.StakeVersionTimeRemaining,
+		UpgradeComplete:    t.PosUpgrade.Completed,
+		UpgradeInterval:    upgradeInterval,
+		Intervals:          intervals,
+	}
+}
+
+// apiAgendas serves every known agenda along with its vote tally.
+func (td *WebUI) apiAgendas(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		writeJSON(w, http.StatusServiceUnavailable, errNoData)
+		return
+	}
+
+	td.writeSnapshotJSON(w, r, t, "agendas", func() any {
+		agendas := make([]apiAgenda, 0, len(t.Agendas))
+		for i := range t.Agendas {
+			agendas = append(agendas, newAPIAgenda(&t.Agendas[i]))
+		}
+		return agendas
+	})
+}
+
+// findAgenda returns the agenda identified by the {id} path value. If the same
+// agenda ID has been voted on in multiple vote versions, the most recent is
+// returned unless the version query parameter is provided.  When no agenda is
+// returned, the response has been written.
+func findAgenda(w http.ResponseWriter, r *http.Request, t *templateFields) *Agenda {
+	id := r.PathValue("id")
+	var version uint64
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		version, err = strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid version"})
+			return nil
+		}
+	}
+
+	agenda := t.agenda(id, uint32(version))
+	if agenda == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "agenda not found"})
+	}
+	return agenda
+}
+
+// apiAgenda serves a single agenda identified by the {id} path value.
+func (td *WebUI) apiAgenda(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		writeJSON(w, http.StatusServiceUnavailable, errNoData)
+		return
+	}
+
+	agenda := findAgenda(w, r, t)
+	if agenda == nil {
+		return
+	}
+
+	key := fmt.Sprintf("agenda/%s/%d", agenda.ID, agenda.VoteVersion)
+	td.writeSnapshotJSON(w, r, t, key, func() any {
+		return newAPIAgenda(agenda)
+	})
+}
+
+// apiVoteProgression serves the progression of the votes cast on a single
+// agenda identified by the {id} path value.
+func (td *WebUI) apiVoteProgression(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		writeJSON(w, http.StatusServiceUnavailable, errNoData)
+		return
+	}
+
+	agenda := findAgenda(w, r, t)
+	if agenda == nil {
+		return
+	}
+
+	key := fmt.Sprintf("progression/%s/%d", agenda.ID, agenda.VoteVersion)
+	td.writeSnapshotJSON(w, r, t, key, func() any {
+		return newAPIVoteProgression(agenda)
+	})
+}
+
+// newAPIVoteProgression returns the progression of the votes cast on agenda.
+func newAPIVoteProgression(agenda *Agenda) *apiVoteProgression {
+	p := &agenda.Progression
+	choices := make([]apiChoiceProgression, 0, len(agenda.VoteChoices))
+	for _, c := range agenda.VoteChoices {
+		counts := p.Counts[c.ID]
+		if counts == nil {
+			counts = []int64{}
+		}
+		choices = append(choices, apiChoiceProgression{ID: c.ID, Counts: counts})
+	}
+	// Order choices by their bits, the same as the agenda choices.
+	sort.Slice(choices, func(i, j int) bool {
+		return agenda.VoteChoices[choices[i].ID].Bits < agenda.VoteChoices[choices[j].ID].Bits
+	})
+
+	heights, invalid, otherVersion := p.Heights, p.Invalid, p.OtherVersion
+	if heights == nil {
+		heights = []int64{}
+	}
+	if invalid == nil {
+		invalid = []int64{}
+	}
+	if otherVersion == nil {
+		otherVersion = []int64{}
+	}
+
+	return &apiVoteProgression{
+		ID:                  agenda.ID,
+		VoteVersion:         agenda.VoteVersion,
+		BucketSize:          p.BucketSize,
+		Heights:             heights,
+		Choices:             choices,
+		ApprovalPercentages: agenda.ApprovalProgression(),
+		QuorumPercentages:   agenda.QuorumProgression(),
+		InvalidCounts:       invalid,
+		OtherVersionCounts:  otherVersion,
+	}
+}
+
+// apiTSpends serves every known tspend along with the votes cast on it.
+func (td *WebUI) apiTSpends(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		writeJSON(w, http.StatusServiceUnavailable, errNoData)
+		return
+	}
+
+	td.writeSnapshotJSON(w, r, t, "tspends", func() any {
+		tspends := make([]apiTSpend, 0, len(t.TSpends))
+		for i := range t.TSpends {
+			tspends = append(tspends, newAPITSpend(&t.TSpends[i]))
+		}
+		return tspends
+	})
+}
+
+func newAPITSpend(ts *TSpend) apiTSpend {
+	a := apiTSpend{
+		Hash:             ts.Hash,
+		Amount:           ts.Amount,
+		Expiry:           ts.Expiry,
+		VoteStart:        ts.VoteStart,
+		VoteEnd:          ts.VoteEnd,
+		Yes:              ts.Yes,
+		No:               ts.No,
+		QuorumThreshold:  ts.QuorumThreshold(),
+		QuorumMet:        ts.QuorumMet(),
+		ApprovalRating:   finite(ts.ApprovalRating()),
+		RequiredApproval: ts.RequiredApproval(),
+		Status:           ts.Status,
+		MinedHeight:      ts.MinedHeight,
+	}
+	if p := ts.Projection; p != nil {
+		a.Projection = &apiTSpendProjection{
+			Height:          p.Height,
+			BlocksRemaining: p.BlocksRemaining,
+			YesPerBlock:     p.YesPerBlock,
+			NoPerBlock:      p.NoPerBlock,
+			FinalApproval:   p.FinalApproval,
+			QuorumProjected: p.QuorumProjected,
+			Approved:        p.Approved,
+			CanPass:         p.CanPass,
+		}
+	}
+	return a
+}
+
+// apiBlockVotes serves the votes of the block identified by the {block} path
+// value, decoded against the agendas of their vote versions.
+func (td *WebUI) apiBlockVotes(w http.ResponseWriter, r *http.Request) {
+	block, code := td.findBlockVotes(r, td.TemplateData.Load())
+	switch code {
+	case http.StatusNotFound:
+		writeJSON(w, code, apiError{Error: "block not found"})
+		return
+	case http.StatusServiceUnavailable:
+		writeJSON(w, code, apiError{Error: "unable to retrieve the block from dcrd"})
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIBlockVotes(block))
+}
+
+func newAPIBlockVotes(block *BlockVotes) *apiBlockVotes {
+	votes := make([]apiBlockVote, 0, len(block.Votes))
+	for _, v := range block.Votes {
+		choices := make([]apiDecodedChoice, 0, len(v.Choices))
+		for _, c := range v.Choices {
+			choices = append(choices, apiDecodedChoice{
+				AgendaID: c.AgendaID,
+				Mask:     c.Mask,
+				Bits:     c.Bits,
+				Choice:   c.Choice,
+				Valid:    c.Valid(),
+			})
+		}
+		votes = append(votes, apiBlockVote{
+			Version:        v.Version,
+			Bits:           v.Bits,
+			ApprovesParent: v.ApprovesParent,
+			UnknownVersion: v.UnknownVersion,
+			Choices:        choices,
+			UndefinedBits:  v.UndefinedBits,
+		})
+	}
+	tallies := make([]apiBlockTally, 0, len(block.Tallies))
+	for _, tally := range block.Tallies {
+		tallies = append(tallies, apiBlockTally{
+			AgendaID:          tally.AgendaID,
+			VoteVersion:       tally.VoteVersion,
+			Counts:            tally.Counts,
+			InvalidVotes:      tally.Invalid,
+			OtherVersionVotes: tally.OtherVersion,
+		})
+	}
+	return &apiBlockVotes{
+		Height:       block.Height,
+		Hash:         block.Hash,
+		StakeVersion: block.StakeVersion,
+		Votes:        votes,
+		Tallies:      tallies,
+	}
+}
+
+// apiParticipation serves the number of votes cast and missed in the
+// recent stake version intervals and in the voting windows of agendas.
+func (td *WebUI) apiParticipation(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		writeJSON(w, http.StatusServiceUnavailable, errNoData)
+		return
+	}
+
+	td.writeSnapshotJSON(w, r, t, "participation", func() any {
+		return newAPIParticipationSummary(t)
+	})
+}
+
+func newAPIParticipationSummary(t *templateFields) *apiParticipationSummary {
+	voters := t.CurrentSVIVoters
+	if voters == nil {
+		voters = []int64{}
+	}
+	return &apiParticipationSummary{
+		TicketsPerBlock:            activeNetParams.TicketsPerBlock,
+		StakeVersionIntervals:      newAPIParticipations(t.SVIParticipation),
+		VotingWindows:              newAPIParticipations(t.RCIParticipation),
+		CurrentIntervalStartHeight: t.CurrentSVIVotersStartHeight(),
+		CurrentIntervalVoters:      voters,
+	}
+}
+
+func newAPIParticipations(ps []Participation) []apiParticipation {
+	participations := make([]apiParticipation, 0, len(ps))
+	for i := range ps {
+		p := &ps[i]
+		participations = append(participations, apiParticipation{
+			StartHeight: p.StartHeight,
+			EndHeight:   p.EndHeight,
+			Blocks:      p.Blocks(),
+			Slots:       p.Slots(),
+			Votes:       p.Votes,
+			MissedVotes: p.Missed(),
+			Percentage:  finite(p.Percentage()),
+		})
+	}
+	return participations
+}
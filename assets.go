@@ -0,0 +1,142 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// immutableCacheControl is the Cache-Control header of static assets requested
+// by their fingerprinted URL, which never changes content.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// embeddedPublic holds the HTML templates and static assets, so that the
+// binary can be run from any working directory without a copy of public.
+//
+//go:embed public
+var embeddedPublic embed.FS
+
+// publicFS returns the file system the HTML templates and static assets are
+// served from.  This is the embedded public directory, unless dir is set, in
+// which case files are read from dir on each request and template reload,
+// so that they can be edited without rebuilding.
+func publicFS(dir string) (fs.FS, error) {
+	if dir == "" {
+		return fs.Sub(embeddedPublic, "public")
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public directory: %w", err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("invalid public directory: %s is not a directory", dir)
+	}
+	return os.DirFS(dir), nil
+}
+
+// staticAssets serves the static assets of a public file system with ETags
+// and brotli or gzip compression.  An asset requested with the fingerprint of its
+// current content in the v query parameter, as the templates link to them
+// with the asset function, may be cached by clients indefinitely.
+type staticAssets struct {
+	fsys fs.FS
+
+	mu     sync.Mutex
+	assets map[string]*staticAsset
+}
+
+// staticAsset is an asset along with the modification time and size of the
+// file it was read from, which are used to detect when a file in a public
+// directory has been edited.
+type staticAsset struct {
+	*cachedResponse
+	size int64
+}
+
+func newStaticAssets(fsys fs.FS) *staticAssets {
+	return &staticAssets{
+		fsys:   fsys,
+		assets: make(map[string]*staticAsset),
+	}
+}
+
+// load returns the asset at name, which is read and compressed the first time
+// it is requested and whenever the file is modified.  Directories are
+// reported as not existing.
+func (s *staticAssets) load(name string) (*cachedResponse, error) {
+	fi, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	s.mu.Lock()
+	asset, ok := s.assets[name]
+	s.mu.Unlock()
+	if ok && asset.modTime.Equal(fi.ModTime()) && asset.size == fi.Size() {
+		return asset.cachedResponse, nil
+	}
+
+	b, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(b)
+	}
+	asset = &staticAsset{
+		cachedResponse: newCachedResponse(contentType, fi.ModTime(), b),
+		size:           fi.Size(),
+	}
+	s.mu.Lock()
+	s.assets[name] = asset
+	s.mu.Unlock()
+	return asset.cachedResponse, nil
+}
+
+// url returns the fingerprinted URL of the asset at the URL path urlPath. It
+// is the asset template function.
+func (s *staticAssets) url(urlPath string) (string, error) {
+	asset, err := s.load(strings.TrimPrefix(urlPath, "/"))
+	if err != nil {
+		return "", err
+	}
+	return urlPath + "?v=" + asset.hash, nil
+}
+
+func (s *staticAssets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	asset, err := s.load(strings.TrimPrefix(path.Clean(r.URL.Path), "/"))
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read static asset %s: %v", r.URL.Path, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+
+	// An outdated fingerprint is served the current content, which must
+	// be revalidated like any other unfingerprinted request.
+	cacheControl := "no-cache"
+	if r.URL.Query().Get("v") == asset.hash {
+		cacheControl = immutableCacheControl
+	}
+	asset.serve(w, r, cacheControl)
+}
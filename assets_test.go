@@ -0,0 +1,116 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// get requests path from srv and returns the response status and body.
+func get(t *testing.T, srv *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+// TestPublicFS ensures that the templates and static assets are served from
+// the embedded public directory, independently of the working directory, and
+// from the public directory option when it is set.
+func TestPublicFS(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	public, err := publicFS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	webUI, err := NewWebUI(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webUI.TemplateData = &templateInformation
+	srv := httptest.NewServer(webUI.router())
+	defer srv.Close()
+
+	want, err := embeddedPublic.ReadFile("public/css/styles.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, body := get(t, srv, "/css/styles.css"); code != http.StatusOK || body != string(want) {
+		t.Errorf("embedded /css/styles.css: status %d, body matches %v", code, body == string(want))
+	}
+	if code, _ := get(t, srv, "/js/missing.js"); code != http.StatusNotFound {
+		t.Errorf("embedded /js/missing.js: status %d, want %d", code, http.StatusNotFound)
+	}
+
+	// A public directory replaces the embedded files entirely.
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"views/start.html": `{{define "home"}}development home{{end}}`,
+		"css/styles.css":   "development styles",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	public, err = publicFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webUI, err = NewWebUI(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webUI.TemplateData = &templateInformation
+	devSrv := httptest.NewServer(webUI.router())
+	defer devSrv.Close()
+
+	if _, body := get(t, devSrv, "/"); !strings.Contains(body, "development home") {
+		t.Errorf("home page %q was not rendered from the public directory", body)
+	}
+	if _, body := get(t, devSrv, "/css/styles.css"); body != "development styles" {
+		t.Errorf("/css/styles.css %q was not served from the public directory", body)
+	}
+	if code, _ := get(t, devSrv, "/js/chart.min.js"); code != http.StatusNotFound {
+		t.Errorf("/js/chart.min.js: status %d, want %d", code, http.StatusNotFound)
+	}
+
+	// Templates edited in the public directory are picked up on reload.
+	err = os.WriteFile(filepath.Join(dir, "views", "start.html"),
+		[]byte(`{{define "home"}}edited home{{end}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := webUI.parseTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	webUI.templ.Store(tmpl)
+	if _, body := get(t, devSrv, "/"); !strings.Contains(body, "edited home") {
+		t.Errorf("home page %q was not rendered from the reloaded templates", body)
+	}
+
+	// A public directory which does not exist is reported.
+	if _, err := publicFS(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("missing public directory was accepted")
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrjson/v4"
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+)
+
+// voteBitsApproveParent is the vote bit which, when set, approves the regular
+// transactions of the previous block.  It is not part of any agenda.
+const voteBitsApproveParent = 0x0001
+
+const (
+	// blockVotesCacheSize is the number of blocks whose decoded votes are
+	// kept in memory.
+	blockVotesCacheSize = 2048
+	// maxBlockVotesLookups is the number of blocks whose votes may be
+	// requested from dcrd at once.
+	maxBlockVotesLookups = 4
+)
+
+var (
+	// errBlockNotFound is returned by blockVotes when the requested block
+	// is not known to dcrd.
+	errBlockNotFound = errors.New("block not found")
+	// errUnknownVoteVersion is returned by voteVersionAgendas when dcrd does
+	// not recognize a vote version.
+	errUnknownVoteVersion = errors.New("unknown vote version")
+)
+
+// BlockVotes is the votes cast in a single block, decoded against the agendas
+// of their vote versions.
+type BlockVotes struct {
+	Height       int64
+	Hash         string
+	StakeVersion uint32
+	Votes        []DecodedVote
+	// Tallies are ordered the same as the agendas of the voting
+	// information.
+	Tallies []BlockTally
+}
+
+// BlockTally is the votes cast in a single block on an agenda whose voting
+// window includes it, in the same way as they are counted for the agenda.
+type BlockTally struct {
+	AgendaID    string
+	VoteVersion uint32
+	// Counts are keyed by vote choice ID.
+	Counts       map[string]int64
+	Invalid      int64
+	OtherVersion int64
+}
+
+// DecodedVote is a single vote and the choice it makes on every agenda of its
+// vote version.
+type DecodedVote struct {
+	Version uint32
+	Bits    uint16
+	// ApprovesParent is whether the vote approves the regular transactions
+	// of the previous block.
+	ApprovesParent bool
+	// UnknownVersion is set when dcrd does not recognize the vote version,
+	// so no agendas can be decoded.
+	UnknownVersion bool
+	// Choices are ordered by agenda ID.
+	Choices []DecodedChoice
+	// UndefinedBits are the bits which are set but are neither the parent
+	// approval bit nor part of the mask of an agenda of the vote version.
+	UndefinedBits uint16
+}
+
+// DecodedChoice is the choice a vote makes on a single agenda.
+type DecodedChoice struct {
+	AgendaID string
+	Mask     uint16
+	// Bits are the vote bits covered by the agenda's mask.
+	Bits uint16
+	// Choice is the ID of the choice with the same bits, or empty if there
+	// is none.
+	Choice string
+}
+
+// Valid indicates if the bits of the vote match a choice of the agenda.
+func (c *DecodedChoice) Valid() bool {
+	return c.Choice != ""
+}
+
+// decodeVote decodes the bits of a vote against the agendas of its vote
+// version.
+func decodeVote(version uint32, bits uint16, agendas []Agenda) DecodedVote {
+	vote := DecodedVote{
+		Version:        version,
+		Bits:           bits,
+		ApprovesParent: bits&voteBitsApproveParent != 0,
+	}
+	defined := uint16(voteBitsApproveParent)
+	for i := range agendas {
+		a := &agendas[i]
+		defined |= a.Mask
+		choice, _ := a.voteChoice(bits)
+		vote.Choices = append(vote.Choices, DecodedChoice{
+			AgendaID: a.ID,
+			Mask:     a.Mask,
+			Bits:     bits & a.Mask,
+			Choice:   choice,
+		})
+	}
+	vote.UndefinedBits = bits &^ defined
+	return vote
+}
+
+// parseBlockID parses a block identifier, which is either a height or a block
+// hash, returning the height when it is not a hash.
+func parseBlockID(id string) (*chainhash.Hash, int64, error) {
+	if len(id) == chainhash.MaxHashStringSize {
+		hash, err := chainhash.NewHashFromStr(id)
+		return hash, -1, err
+	}
+	height, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || height < 0 {
+		return nil, -1, fmt.Errorf("invalid block height %q", id)
+	}
+	return nil, height, nil
+}
+
+// isRPCError returns whether err is an error returned by dcrd with the
+// provided JSON-RPC error code.
+func isRPCError(err error, code dcrjson.RPCErrorCode) bool {
+	var rpcErr *dcrjson.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == code
+}
+
+// blockVotesCache holds the decoded votes of recently requested blocks, keyed
+// by block hash, and limits the number of blocks looked up from dcrd at once.
+// The votes of a block never change, so they are only evicted to bound the
+// memory used.  The tallies depend on the voting information they are
+// requested with, so they are not cached.
+type blockVotesCache struct {
+	lookups chan struct{}
+
+	mtx     sync.Mutex
+	size    int
+	recent  *list.List // of *BlockVotes, most recently used first
+	entries map[string]*list.Element
+}
+
+func newBlockVotesCache(size, maxLookups int) *blockVotesCache {
+	return &blockVotesCache{
+		lookups: make(chan struct{}, maxLookups),
+		size:    size,
+		recent:  list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *blockVotesCache) get(hash string) *BlockVotes {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.entries[hash]
+	if !ok {
+		return nil
+	}
+	c.recent.MoveToFront(e)
+	return e.Value.(*BlockVotes)
+}
+
+func (c *blockVotesCache) put(block *BlockVotes) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if e, ok := c.entries[block.Hash]; ok {
+		c.recent.MoveToFront(e)
+		return
+	}
+	c.entries[block.Hash] = c.recent.PushFront(block)
+	if c.recent.Len() > c.size {
+		oldest := c.recent.Remove(c.recent.Back()).(*BlockVotes)
+		delete(c.entries, oldest.Hash)
+	}
+}
+
+// blockVotes returns the decoded votes of the block identified by id, which is
+// a height or a block hash, along with their tallies on the agendas of the
+// voting information t.  The agendas of vote versions in t are used to decode
+// the votes, and the others are requested from dcrd.  Once the limit of
+// concurrent lookups is reached, it waits for one to finish or for ctx to be
+// done.
+func (c *blockVotesCache) blockVotes(ctx context.Context, chain chainSource, t *templateFields, id string) (*BlockVotes, error) {
+	hash, height, err := parseBlockID(id)
+	if err != nil {
+		return nil, errBlockNotFound
+	}
+	if hash != nil {
+		if block := c.get(hash.String()); block != nil {
+			return block.withTallies(t), nil
+		}
+	}
+
+	select {
+	case c.lookups <- struct{}{}:
+		defer func() { <-c.lookups }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if hash == nil {
+		hash, err = chain.GetBlockHash(ctx, height)
+		if err != nil {
+			if isRPCError(err, dcrjson.ErrRPCOutOfRange) {
+				return nil, errBlockNotFound
+			}
+			return nil, fmt.Errorf("GetBlockHash error: %w", err)
+		}
+		if block := c.get(hash.String()); block != nil {
+			return block.withTallies(t), nil
+		}
+	} else {
+		// getstakeversions does not distinguish unknown blocks from
+		// other failures.
+		_, err := chain.GetBlockHeader(ctx, hash)
+		if err != nil {
+			if isRPCError(err, dcrjson.ErrRPCBlockNotFound) {
+				return nil, errBlockNotFound
+			}
+			return nil, fmt.Errorf("GetBlockHeader error: %w", err)
+		}
+	}
+
+	block, err := decodeBlockVotes(ctx, chain, t, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.put(block)
+	return block.withTallies(t), nil
+}
+
+// decodeBlockVotes returns the decoded votes of the block with the provided
+// hash, without tallies.
+func decodeBlockVotes(ctx context.Context, chain chainSource, t *templateFields, hash *chainhash.Hash) (*BlockVotes, error) {
+	stakeVersions, err := chain.GetStakeVersions(ctx, hash.String(), 1)
+	if err != nil {
+		return nil, fmt.Errorf("GetStakeVersions error: %w", err)
+	}
+	if len(stakeVersions.StakeVersions) != 1 {
+		return nil, fmt.Errorf("GetStakeVersions returned %d blocks, want 1",
+			len(stakeVersions.StakeVersions))
+	}
+
+	sv := &stakeVersions.StakeVersions[0]
+	block := &BlockVotes{
+		Height:       sv.Height,
+		Hash:         sv.Hash,
+		StakeVersion: sv.StakeVersion,
+	}
+	agendas := make(map[uint32][]Agenda)
+	unknown := make(map[uint32]bool)
+	for _, v := range sv.Votes {
+		if _, ok := agendas[v.Version]; !ok && !unknown[v.Version] {
+			versionAgendas, err := voteVersionAgendas(ctx, chain, t, v.Version)
+			switch {
+			case errors.Is(err, errUnknownVoteVersion):
+				unknown[v.Version] = true
+			case err != nil:
+				return nil, err
+			default:
+				agendas[v.Version] = versionAgendas
+			}
+		}
+		vote := decodeVote(v.Version, v.Bits, agendas[v.Version])
+		vote.UnknownVersion = unknown[v.Version]
+		block.Votes = append(block.Votes, vote)
+	}
+	return block, nil
+}
+
+// withTallies returns a copy of the block votes with their tallies on each
+// agenda of the voting information t whose voting window includes the block.
+func (b *BlockVotes) withTallies(t *templateFields) *BlockVotes {
+	votes := make([]types.VersionBits, len(b.Votes))
+	for i, v := range b.Votes {
+		votes[i] = types.VersionBits{Version: v.Version, Bits: v.Bits}
+	}
+	block := *b
+	block.Tallies = nil
+	for i := range t.Agendas {
+		a := &t.Agendas[i]
+		if a.VotingStarted() && a.StartHeight <= b.Height && b.Height <= a.EndHeight {
+			block.Tallies = append(block.Tallies, tallyBlock(a, votes))
+		}
+	}
+	return &block
+}
+
+// voteVersionAgendas returns the agendas of a vote version, ordered by ID, or
+// errUnknownVoteVersion if dcrd does not recognize the vote version.
+func voteVersionAgendas(ctx context.Context, chain chainSource, t *templateFields,
+	version uint32) ([]Agenda, error) {
+
+	var agendas []Agenda
+	for _, a := range t.Agendas {
+		if a.VoteVersion == version {
+			agendas = append(agendas, a)
+		}
+	}
+	if agendas == nil {
+		voteInfo, err := chain.GetVoteInfo(ctx, version)
+		if err != nil {
+			// The vote version is the only parameter of getvoteinfo.
+			if isRPCError(err, dcrjson.ErrRPCInvalidParameter) {
+				return nil, errUnknownVoteVersion
+			}
+			return nil, fmt.Errorf("GetVoteInfo error: %w", err)
+		}
+		agendas = agendasFromJSON(*voteInfo)
+		if agendas == nil {
+			agendas = []Agenda{}
+		}
+	}
+	sort.Slice(agendas, func(i, j int) bool {
+		return agendas[i].ID < agendas[j].ID
+	})
+	return agendas, nil
+}
+
+// tallyBlock counts the votes of a block on an agenda.
+func tallyBlock(a *Agenda, votes []types.VersionBits) BlockTally {
+	tally := BlockTally{
+		AgendaID:    a.ID,
+		VoteVersion: a.VoteVersion,
+		Counts:      make(map[string]int64, len(a.VoteChoices)),
+	}
+	for vID := range a.VoteChoices {
+		tally.Counts[vID] = 0
+	}
+	for _, vote := range votes {
+		a.tallyVote(vote, tally.Counts, &tally.Invalid, &tally.OtherVersion)
+	}
+	return tally
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestBlockVotes ensures that the votes of a block, identified by height or
+// hash, are decoded against every agenda of their vote version, including bits
+// which match no choice, bits outside of every agenda and unknown versions.
+func TestBlockVotes(t *testing.T) {
+	chain := lockedInChain()
+	h := newTestHarness(t, chain)
+	h.mineTo(901, 12, 12, concatVotes(
+		votes(1, 12, voteBitsApproveParent|bitsYes),
+		votes(1, 12, bitsYes|bitsNo),
+		votes(1, 12, 0x0100|bitsNo),
+		votes(1, 13, voteBitsApproveParent),
+	))
+	hash := chain.tip().hash.String()
+
+	want := &apiBlockVotes{
+		Height:       901,
+		Hash:         hash,
+		StakeVersion: 12,
+		Votes: []apiBlockVote{{
+			Version:        12,
+			Bits:           0x0005,
+			ApprovesParent: true,
+			Choices: []apiDecodedChoice{{
+				AgendaID: "maxtreasuryspend", Mask: 0x06, Bits: 0x04,
+				Choice: "yes", Valid: true,
+			}},
+		}, {
+			Version: 12,
+			Bits:    0x0006,
+			Choices: []apiDecodedChoice{{
+				AgendaID: "maxtreasuryspend", Mask: 0x06, Bits: 0x06,
+			}},
+		}, {
+			Version: 12,
+			Bits:    0x0102,
+			Choices: []apiDecodedChoice{{
+				AgendaID: "maxtreasuryspend", Mask: 0x06, Bits: 0x02,
+				Choice: "no", Valid: true,
+			}},
+			UndefinedBits: 0x0100,
+		}, {
+			Version:        13,
+			Bits:           0x0001,
+			ApprovesParent: true,
+			UnknownVersion: true,
+			Choices:        []apiDecodedChoice{},
+		}},
+		// The voting window of the agenda has ended.
+		Tallies: []apiBlockTally{},
+	}
+	for _, id := range []string{"901", hash} {
+		var got apiBlockVotes
+		h.getJSON("/api/v1/blocks/"+id+"/votes", &got)
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("block %s votes:\ngot  %+v\nwant %+v", id, got, *want)
+		}
+	}
+
+	const path = "/block/901"
+	page := h.get(path, http.StatusOK)
+	h.assertContains(path, page, "Block 901 Votes", hash,
+		"maxtreasuryspend:", "invalid 0x0006", "0x0100", "v13 unknown",
+		`href="/block/900"`, `href="/block/902"`)
+
+	// Blocks before the stake validation height have no votes.
+	var early apiBlockVotes
+	h.getJSON("/api/v1/blocks/10/votes", &early)
+	if early.Height != 10 || len(early.Votes) != 0 {
+		t.Errorf("unexpected votes of block 10: %+v", early)
+	}
+
+	for _, path := range []string{
+		"/block/902",
+		"/block/-1",
+		"/block/latest",
+		"/block/" + chain.params.GenesisHash.String()[:63] + "0",
+		"/api/v1/blocks/902/votes",
+	} {
+		h.get(path, http.StatusNotFound)
+	}
+
+	// The votes of a block are decoded once, while heights are resolved to
+	// their main chain block on each request.
+	decoded := func() (n int) {
+		for _, req := range h.dcrd.requestsOf("getstakeversions") {
+			var reqHash string
+			if err := param(req, 0, &reqHash); err != nil {
+				t.Fatal(err)
+			}
+			if reqHash == hash {
+				n++
+			}
+		}
+		return n
+	}
+	decodes, blockHashes := decoded(), len(h.dcrd.requestsOf("getblockhash"))
+	h.get(path, http.StatusOK)
+	h.get("/api/v1/blocks/"+hash+"/votes", http.StatusOK)
+	if n := len(h.dcrd.requestsOf("getblockhash")) - blockHashes; n != 1 {
+		t.Errorf("height 901 resolved %d times, want 1", n)
+	}
+	if n := decoded() - decodes; n != 0 {
+		t.Errorf("cached block 901 votes requested %d times, want 0", n)
+	}
+
+	// Failures of dcrd other than unknown blocks are not reported as such.
+	h.dcrd.setFailing("getblockheader", true)
+	h.get("/api/v1/blocks/"+chain.blocks[900].hash.String()+"/votes",
+		http.StatusServiceUnavailable)
+	h.dcrd.setFailing("getblockheader", false)
+
+	// Blocks can not be looked up while dcrd is unavailable.
+	h.dcrd.setOnline(false)
+	waitForSnapshot(t, func(t *templateFields) bool { return !t.BackendAvailable })
+	h.get(path, http.StatusServiceUnavailable)
+	h.get("/api/v1/blocks/901/votes", http.StatusServiceUnavailable)
+}
+
+// TestInvalidVotes ensures that votes whose bits match no choice of an agenda,
+// and votes of other vote versions, are counted separately from the choices
+// in total, at each point of the vote progression, in each block, and when
+// the tally of a finished voting window is cached.
+func TestInvalidVotes(t *testing.T) {
+	const agendaID = "maxtreasuryspend"
+
+	// Each block of the voting window 464-783 has one invalid vote and one
+	// vote of the previous vote version.
+	ballot := concatVotes(votes(2, 12, bitsYes), votes(1, 12, bitsYes|bitsNo),
+		votes(1, 11, bitsYes), votes(1, 12, bitsAbstain))
+	chain := upgradingChain()
+	chain.mineTo(367, 12, 11, votes(5, 12, 0))
+	chain.setStatus(12, agendaID, "started")
+	cachePath := filepath.Join(t.TempDir(), voteCacheFilename)
+	h := newTestHarnessWithCache(t, chain, cachePath)
+	h.mineTo(500, 12, 12, ballot)
+
+	a := h.agenda(agendaID)
+	if a.InvalidVotes != 37 || a.OtherVersionVotes != 37 || a.VoteCounts["yes"] != 74 ||
+		a.TotalVotes() != 111 {
+		t.Fatalf("invalid %d other version %d counts %v, want 37 37 yes 74 abstain 37",
+			a.InvalidVotes, a.OtherVersionVotes, a.VoteCounts)
+	}
+
+	var agenda apiAgenda
+	h.getJSON("/api/v1/agendas/"+agendaID, &agenda)
+	if agenda.InvalidVotes != 37 || agenda.OtherVersionVotes != 37 {
+		t.Errorf("API invalid %d other version %d, want 37 37",
+			agenda.InvalidVotes, agenda.OtherVersionVotes)
+	}
+	var progression apiVoteProgression
+	h.getJSON("/api/v1/agendas/"+agendaID+"/progression", &progression)
+	if n := len(progression.Heights); n != 10 ||
+		len(progression.InvalidCounts) != n || progression.InvalidCounts[0] != 4 ||
+		progression.InvalidCounts[9] != 37 || progression.OtherVersionCounts[9] != 37 {
+		t.Errorf("API progression invalid %v other version %v, want [4 8 ... 36 37]",
+			progression.InvalidCounts, progression.OtherVersionCounts)
+	}
+
+	var block apiBlockVotes
+	h.getJSON("/api/v1/blocks/500/votes", &block)
+	want := []apiBlockTally{{
+		AgendaID:          agendaID,
+		VoteVersion:       12,
+		Counts:            map[string]int64{"abstain": 1, "no": 0, "yes": 2},
+		InvalidVotes:      1,
+		OtherVersionVotes: 1,
+	}}
+	if !reflect.DeepEqual(block.Tallies, want) {
+		t.Errorf("block 500 tallies %+v, want %+v", block.Tallies, want)
+	}
+
+	const path = "/agenda/" + agendaID
+	h.assertContains(path, h.get(path, http.StatusOK), "Invalid Votes", "<td>37</td>")
+	h.assertPage("Invalid Votes:", "Other Versions:")
+	for sample, want := range map[string]float64{
+		`dcrvotingweb_agenda_invalid_votes{agenda="maxtreasuryspend",vote_version="12"}`:       37,
+		`dcrvotingweb_agenda_other_version_votes{agenda="maxtreasuryspend",vote_version="12"}`: 37,
+	} {
+		if got := h.metric(sample); got != want {
+			t.Errorf("%s = %v, want %v", sample, got, want)
+		}
+	}
+
+	// The totals of the finished voting window are retained when the
+	// cached tally is used.
+	h.mineTo(783, 12, 12, ballot)
+	chain.setStatus(12, agendaID, "lockedin")
+	h.mineTo(900, 12, 12, ballot)
+	h = newTestHarnessWithCache(t, chain, cachePath)
+	if a := h.agenda(agendaID); a.InvalidVotes != 320 || a.OtherVersionVotes != 320 {
+		t.Errorf("cached invalid %d other version %d, want 320 320",
+			a.InvalidVotes, a.OtherVersionVotes)
+	}
+}
+
+// TestBlockVotesCache ensures that the least recently used block votes are
+// evicted, and that lookups wait for one of the limited number of concurrent
+// lookups to finish.
+func TestBlockVotesCache(t *testing.T) {
+	c := newBlockVotesCache(2, 1)
+	for _, hash := range []string{"a", "b", "a", "c"} {
+		if c.get(hash) == nil {
+			c.put(&BlockVotes{Hash: hash})
+		}
+	}
+	for hash, cached := range map[string]bool{"a": true, "b": false, "c": true} {
+		if got := c.get(hash) != nil; got != cached {
+			t.Errorf("block %s cached: got %v, want %v", hash, got, cached)
+		}
+	}
+
+	// With every lookup in progress, blocks which are not cached are not
+	// requested from dcrd until the context is done.
+	c.lookups <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.blockVotes(ctx, nil, &templateFields{}, "100"); !errors.Is(err, context.Canceled) {
+		t.Errorf("lookup while busy: got %v, want %v", err, context.Canceled)
+	}
+}
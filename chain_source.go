@@ -0,0 +1,48 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil/v4"
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/rpcclient/v8"
+	"github.com/decred/dcrd/wire"
+)
+
+// chainSource provides all of the chain data required to compute the voting
+// information displayed by dcrvotingweb. It is satisfied by a dcrd
+// *rpcclient.Client, but allows alternate backends and in-memory fakes to be
+// used instead.
+type chainSource interface {
+	// GetStakeVersions returns the stake versions and votes of count blocks,
+	// starting at the block with the provided hash and walking backwards.
+	GetStakeVersions(ctx context.Context, hash string, count int32) (*types.GetStakeVersionsResult, error)
+	// GetStakeVersionInfo returns the vote versions seen in the most recent
+	// count stake version intervals, ordered newest to oldest.
+	GetStakeVersionInfo(ctx context.Context, count int32) (*types.GetStakeVersionInfoResult, error)
+	// GetVoteInfo returns the agendas and their status for a vote version.
+	GetVoteInfo(ctx context.Context, version uint32) (*types.GetVoteInfoResult, error)
+	// GetBlockHash returns the hash of the main chain block at a height.
+	GetBlockHash(ctx context.Context, blockHeight int64) (*chainhash.Hash, error)
+	// GetBestBlockHash returns the hash of the current main chain tip.
+	GetBestBlockHash(ctx context.Context) (*chainhash.Hash, error)
+	// GetBlockHeader returns the header of the block with the provided hash.
+	GetBlockHeader(ctx context.Context, hash *chainhash.Hash) (*wire.BlockHeader, error)
+	// GetBlock returns the block with the provided hash.
+	GetBlock(ctx context.Context, hash *chainhash.Hash) (*wire.MsgBlock, error)
+	// GetRawTransaction returns the transaction with the provided hash.
+	GetRawTransaction(ctx context.Context, txHash *chainhash.Hash) (*dcrutil.Tx, error)
+	// GetRawMempool returns the hashes of the mempool transactions of a type.
+	GetRawMempool(ctx context.Context, txType types.GetRawMempoolTxTypeCmd) ([]*chainhash.Hash, error)
+	// GetTreasurySpendVotes returns the votes cast on treasury spends up to
+	// and including the provided block, or the main chain tip if it is nil.
+	GetTreasurySpendVotes(ctx context.Context, block *chainhash.Hash, tspends []*chainhash.Hash) (*types.GetTreasurySpendVotesResult, error)
+}
+
+// Ensure a dcrd RPC client can be used as a chainSource.
+var _ chainSource = (*rpcclient.Client)(nil)
@@ -1,4 +1,4 @@
-// Copyright (c) 2017-2023 The Decred developers
+// Copyright (c) 2017-2025 The Decred developers
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg/v3"
 	"github.com/decred/dcrd/dcrutil/v4"
@@ -19,26 +20,56 @@ import (
 
 const (
 	defaultConfigFilename = "dcrvotingweb.conf"
+	voteCacheFilename     = "votecache.json"
+	agendaCatalogFilename = "agendas.json"
+)
 
-	// blockVersionMain is the version of the block being generated for
-	// the main network.
-	blockVersionMain = 11
+// netDefaults are the defaults which depend on the network.
+type netDefaults struct {
+	params           *chaincfg.Params
+	blockExplorerURL string
+	rpcPort          string
+}
 
-	// blockVersionTest is the version of the block being generated
-	// for the testnet network.
-	blockVersionTest = 12
+var (
+	mainNetDefaults = netDefaults{
+		params:           chaincfg.MainNetParams(),
+		blockExplorerURL: "https://mainnet.dcrdata.org",
+		rpcPort:          "9109",
+	}
+	testNetDefaults = netDefaults{
+		params:           chaincfg.TestNet3Params(),
+		blockExplorerURL: "https://testnet.dcrdata.org",
+		rpcPort:          "19109",
+	}
+	// There are no public block explorers for simnet and regnet, so default
+	// to a dcrdata instance running locally.
+	simNetDefaults = netDefaults{
+		params:           chaincfg.SimNetParams(),
+		blockExplorerURL: "http://127.0.0.1:7777",
+		rpcPort:          "19556",
+	}
+	regNetDefaults = netDefaults{
+		params:           chaincfg.RegNetParams(),
+		blockExplorerURL: "http://127.0.0.1:7777",
+		rpcPort:          "18656",
+	}
 )
 
 var (
 	// Default network parameters
 	activeNetParams *chaincfg.Params
-	// stakeVersion is the stake version we call getvoteinfo with.
+	// blockVersion is the block version set with --blockversion, or zero
+	// when the expected block version is derived from activeNetParams and
+	// the chain.
 	blockVersion int32
 
 	// Default configuration options
 	defaultConfigFile  = filepath.Join(defaultHomeDir, defaultConfigFilename)
 	defaultHomeDir     = dcrutil.AppDataDir("dcrvotingweb", false)
 	defaultRPCCertFile = filepath.Join(defaultHomeDir, "rpc.cert")
+	defaultDataDir     = filepath.Join(defaultHomeDir, "data")
+	defaultCatalogFile = filepath.Join(defaultHomeDir, agendaCatalogFilename)
 	defaultListenPort  = "8000"
 )
 
@@ -46,13 +77,23 @@ var (
 //
 // See loadConfig for details on the configuration load process.
 type config struct {
-	Listen     string `short:"l" long:"listen" description:"Listen on [host]:port"`
-	TestNet    bool   `long:"testnet" description:"Use the test network"`
-	RPCHost    string `short:"c" long:"rpchost" description:"Hostname/IP and port of dcrd RPC server to connect to"`
-	RPCUser    string `short:"u" long:"rpcuser" description:"Username for RPC connections"`
-	RPCPass    string `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
-	RPCCert    string `long:"rpccert" description:"File containing the dcrd certificate file"`
-	DisableTLS bool   `long:"notls" description:"Disable TLS on the RPC client"`
+	Listen        []string      `short:"l" long:"listen" description:"Listen on [host]:port, or on a unix socket with unix:/path/to/socket (may be repeated)"`
+	TLSCert       string        `long:"tlscert" description:"File containing the certificate to serve HTTPS with, which is reloaded when modified"`
+	TLSKey        string        `long:"tlskey" description:"File containing the key of the HTTPS certificate"`
+	TestNet       bool          `long:"testnet" description:"Use the test network"`
+	SimNet        bool          `long:"simnet" description:"Use the simulation test network"`
+	RegNet        bool          `long:"regnet" description:"Use the regression test network"`
+	RPCHost       string        `short:"c" long:"rpchost" description:"Hostname/IP and port of dcrd RPC server to connect to"`
+	RPCUser       string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
+	RPCPass       string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
+	RPCCert       string        `long:"rpccert" description:"File containing the dcrd certificate file"`
+	DisableTLS    bool          `long:"notls" description:"Disable TLS on the RPC client"`
+	DataDir       string        `long:"datadir" description:"Directory to store cached voting data"`
+	ExplorerURL   string        `long:"explorerurl" description:"Block explorer URL used for links to blocks"`
+	AgendaCatalog string        `long:"agendacatalog" description:"JSON file of agenda metadata which adds to or replaces the built-in metadata"`
+	BlockVersion  int32         `long:"blockversion" description:"Block version the PoW upgrade progress is measured against (default: derived from the network deployments and the chain)"`
+	MaxUpdateAge  time.Duration `long:"maxupdateage" description:"Maximum age of the voting information before /readyz reports not ready, which is measured from the last block (0 to disable)"`
+	PublicDir     string        `long:"publicdir" description:"Serve the HTML templates and static assets from this directory instead of those built in, e.g. public for development"`
 }
 
 // cleanAndExpandPath expands environment variables and leading ~ in the
@@ -101,8 +142,10 @@ func loadConfig() (*config, error) {
 
 	// Default config.
 	cfg := config{
-		Listen:  net.JoinHostPort("localhost", defaultListenPort),
-		RPCCert: defaultRPCCertFile,
+		Listen:        []string{net.JoinHostPort("localhost", defaultListenPort)},
+		RPCCert:       defaultRPCCertFile,
+		DataDir:       defaultDataDir,
+		AgendaCatalog: defaultCatalogFile,
 	}
 
 	preCfg := cfg
@@ -144,25 +187,74 @@ func loadConfig() (*config, error) {
 		return nil, err
 	}
 
-	var blockExplorerURL string
-	var defaultRPCPort string
-
+	// Multiple networks can't be selected simultaneously.
+	netDefs := mainNetDefaults
+	numNets := 0
 	if cfg.TestNet {
-		activeNetParams = chaincfg.TestNet3Params()
-		blockVersion = blockVersionTest
-		blockExplorerURL = "https://testnet.dcrdata.org"
-		defaultRPCPort = "19109"
-	} else {
-		activeNetParams = chaincfg.MainNetParams()
-		blockVersion = blockVersionMain
-		blockExplorerURL = "https://mainnet.dcrdata.org"
-		defaultRPCPort = "9109"
-	}
-
-	cfg.Listen = normalizeAddress(cfg.Listen, defaultListenPort)
+		netDefs = testNetDefaults
+		numNets++
+	}
+	if cfg.SimNet {
+		netDefs = simNetDefaults
+		numNets++
+	}
+	if cfg.RegNet {
+		netDefs = regNetDefaults
+		numNets++
+	}
+	if numNets > 1 {
+		err := errors.New("the testnet, simnet and regnet params can't be " +
+			"used together -- choose one of the three")
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, err
+	}
+	activeNetParams = netDefs.params
+	if cfg.BlockVersion < 0 {
+		err := fmt.Errorf("invalid block version %d", cfg.BlockVersion)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, err
+	}
+	blockVersion = cfg.BlockVersion
+	if cfg.MaxUpdateAge < 0 {
+		err := fmt.Errorf("invalid maximum update age %v", cfg.MaxUpdateAge)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, err
+	}
+	defaultRPCPort := netDefs.rpcPort
+	if cfg.ExplorerURL == "" {
+		cfg.ExplorerURL = netDefs.blockExplorerURL
+	}
+	cfg.ExplorerURL = strings.TrimSuffix(cfg.ExplorerURL, "/")
+
+	for i, addr := range cfg.Listen {
+		if path, ok := strings.CutPrefix(addr, unixListenPrefix); ok {
+			cfg.Listen[i] = unixListenPrefix + cleanAndExpandPath(path)
+			continue
+		}
+		cfg.Listen[i] = normalizeAddress(addr, defaultListenPort)
+	}
 	cfg.RPCHost = normalizeAddress(cfg.RPCHost, defaultRPCPort)
 
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
+	cfg.DataDir = cleanAndExpandPath(cfg.DataDir)
+	cfg.AgendaCatalog = cleanAndExpandPath(cfg.AgendaCatalog)
+	if cfg.PublicDir != "" {
+		cfg.PublicDir = cleanAndExpandPath(cfg.PublicDir)
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		err := errors.New("the tlscert and tlskey options must be used together")
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, err
+	}
+	if cfg.TLSCert != "" {
+		cfg.TLSCert = cleanAndExpandPath(cfg.TLSCert)
+		cfg.TLSKey = cleanAndExpandPath(cfg.TLSKey)
+	}
 
 	if cfg.RPCHost == "" {
 		cfg.RPCHost = net.JoinHostPort("localhost", defaultRPCPort)
@@ -174,7 +266,15 @@ func loadConfig() (*config, error) {
 	}
 
 	// Set all activeNetParams fields now that we know what network we are on.
-	templateInformation = &templateFields{
+	templateInformation.Store(newTemplateFields(cfg.ExplorerURL))
+
+	return &cfg, nil
+}
+
+// newTemplateFields returns the template data populated with the fields which
+// are derived from activeNetParams and therefore never change.
+func newTemplateFields(blockExplorerURL string) *templateFields {
+	return &templateFields{
 		Network:          activeNetParams.Name,
 		BlockExplorerURL: blockExplorerURL,
 
@@ -189,6 +289,4 @@ func loadConfig() (*config, error) {
 
 		RuleChangeActivationInterval: int64(activeNetParams.RuleChangeActivationInterval),
 	}
-
-	return &cfg, nil
 }
@@ -0,0 +1,243 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/decred/dcrd/rpcclient/v8"
+	"github.com/decred/dcrd/wire"
+)
+
+// blockNotification is a block connected or disconnected notification
+// received from dcrd.
+type blockNotification struct {
+	header wire.BlockHeader
+	// connected is false when the block has been disconnected from the main
+	// chain.
+	connected bool
+}
+
+// notificationQueue is an unbounded queue of block notifications.  Pushing to
+// the queue never blocks, which is required because the dcrd RPC client
+// delivers notifications from the same goroutine which reads RPC responses.
+type notificationQueue struct {
+	mtx   sync.Mutex
+	ntfns []blockNotification
+	// ready is signaled when the queue becomes non-empty.
+	ready chan struct{}
+}
+
+func newNotificationQueue() *notificationQueue {
+	return &notificationQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push adds a notification to the end of the queue.
+func (q *notificationQueue) push(ntfn blockNotification) {
+	q.mtx.Lock()
+	q.ntfns = append(q.ntfns, ntfn)
+	q.mtx.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// popAll removes and returns every queued notification, oldest first.
+func (q *notificationQueue) popAll() []blockNotification {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	ntfns := q.ntfns
+	q.ntfns = nil
+	return ntfns
+}
+
+// blockNotificationHandlers returns dcrd notification handlers which queue
+// the header of every block connected to or disconnected from the main chain.
+func blockNotificationHandlers(queue *notificationQueue) *rpcclient.NotificationHandlers {
+	return &rpcclient.NotificationHandlers{
+		OnBlockConnected: func(serializedBlockHeader []byte, _ [][]byte) {
+			var blockHeader wire.BlockHeader
+			errLocal := blockHeader.FromBytes(serializedBlockHeader)
+			if errLocal != nil {
+				log.Printf("Failed to deserialize block header: %v", errLocal)
+				return
+			}
+			log.Printf("Received new block %v (height %d)", blockHeader.BlockHash(),
+				blockHeader.Height)
+			dcrdTipHeight.Store(int64(blockHeader.Height))
+			queue.push(blockNotification{header: blockHeader, connected: true})
+		},
+		OnBlockDisconnected: func(serializedBlockHeader []byte) {
+			var blockHeader wire.BlockHeader
+			errLocal := blockHeader.FromBytes(serializedBlockHeader)
+			if errLocal != nil {
+				log.Printf("Failed to deserialize block header: %v", errLocal)
+				return
+			}
+			log.Printf("Received disconnected block %v (height %d)",
+				blockHeader.BlockHash(), blockHeader.Height)
+			dcrdTipHeight.Store(int64(blockHeader.Height) - 1)
+			queue.push(blockNotification{header: blockHeader})
+		},
+	}
+}
+
+// connectDcrd creates a websocket RPC client connected to the dcrd instance
+// described by cfg, and subscribes it to block notifications.
+func connectDcrd(ctx context.Context, cfg *config, ntfnHandlers *rpcclient.NotificationHandlers) (*rpcclient.Client, error) {
+	// Read in current dcrd cert
+	var dcrdCerts []byte
+	if !cfg.DisableTLS {
+		var err error
+		dcrdCerts, err = os.ReadFile(cfg.RPCCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dcrd cert file at %v: %w",
+				cfg.RPCCert, err)
+		}
+	}
+
+	// rpclient configuration
+	connCfgDaemon := &rpcclient.ConnConfig{
+		Host:         cfg.RPCHost,
+		Endpoint:     "ws",
+		User:         cfg.RPCUser,
+		Pass:         cfg.RPCPass,
+		Certificates: dcrdCerts,
+		DisableTLS:   cfg.DisableTLS,
+		// Reconnection is handled by dcrdBackend, which also catches up
+		// with any blocks missed while disconnected.
+		DisableAutoReconnect: true,
+	}
+
+	log.Printf("Attempting to connect to dcrd RPC %s as user %s "+
+		"using certificate %s", cfg.RPCHost, cfg.RPCUser, cfg.RPCCert)
+	// Attempt to connect rpcclient and daemon
+	dcrdClient, err := rpcclient.New(connCfgDaemon, ntfnHandlers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start dcrd rpcclient: %w", err)
+	}
+
+	// Subscribe to block notifications
+	if err = dcrdClient.NotifyBlocks(ctx); err != nil {
+		dcrdClient.Disconnect()
+		return nil, fmt.Errorf("failed to register daemon rpc client for "+
+			"block notifications: %w", err)
+	}
+
+	return dcrdClient, nil
+}
+
+const (
+	// minReconnectDelay and maxReconnectDelay bound the delay between
+	// attempts to connect to dcrd, which doubles after each failed attempt.
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// dcrdBackend maintains the connection to dcrd used by an updater. Whenever
+// the connection is lost the updater reports dcrd as unavailable, and the
+// backend reconnects with an exponential backoff.
+type dcrdBackend struct {
+	cfg *config
+	u   *updater
+
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+func newDcrdBackend(cfg *config, u *updater) *dcrdBackend {
+	return &dcrdBackend{
+		cfg:      cfg,
+		u:        u,
+		minDelay: minReconnectDelay,
+		maxDelay: maxReconnectDelay,
+	}
+}
+
+// run connects to dcrd and keeps the voting information current until the
+// context is canceled.
+func (b *dcrdBackend) run(ctx context.Context) {
+	delay := b.minDelay
+	for {
+		connected, err := b.session(ctx)
+		if ctx.Err() != nil {
+			log.Println("Closing dcrvotingweb")
+			return
+		}
+		log.Printf("dcrd is unavailable: %v", err)
+		b.u.setUnavailable(err)
+
+		if connected {
+			delay = b.minDelay
+		}
+		log.Printf("Reconnecting to dcrd in %v", delay)
+		reconnect := time.After(delay)
+	wait:
+		for {
+			select {
+			case <-reconnect:
+				break wait
+			case catalog := <-b.u.catalogs:
+				b.u.applyCatalog(catalog)
+			case <-ctx.Done():
+				log.Println("Closing dcrvotingweb")
+				return
+			}
+		}
+		delay = min(delay*2, b.maxDelay)
+	}
+}
+
+// dcrdChain is the chainSource of the current connection to dcrd, which the
+// web server queries directly for data outside of the voting information, such
+// as the votes of arbitrary blocks.  It is nil while dcrd is not connected.
+var dcrdChain atomic.Pointer[instrumentedChain]
+
+// session connects to dcrd, catches up with the current main chain tip and
+// then processes block notifications until the connection is lost.  The
+// returned bool reports whether the catch up succeeded.
+func (b *dcrdBackend) session(ctx context.Context) (bool, error) {
+	dcrdClient, err := connectDcrd(ctx, b.cfg, blockNotificationHandlers(b.u.ntfns))
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		log.Printf("Disconnecting from dcrd.")
+		dcrdChain.Store(nil)
+		dcrdClient.Shutdown()
+		dcrdClient.WaitForShutdown()
+	}()
+
+	// The client shuts itself down when the connection is lost.
+	disconnected := make(chan struct{})
+	go func() {
+		dcrdClient.WaitForShutdown()
+		close(disconnected)
+	}()
+
+	chain := &instrumentedChain{chain: dcrdClient, metrics: serviceMetrics}
+	b.u.chain = chain
+	dcrdChain.Store(chain)
+	if err := b.u.catchUp(ctx); err != nil {
+		return false, err
+	}
+	b.u.run(ctx, disconnected)
+
+	if err := ctx.Err(); err != nil {
+		return true, err
+	}
+	return true, errors.New("connection to dcrd lost")
+}
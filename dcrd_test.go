@@ -0,0 +1,108 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForSnapshot blocks until the published voting information satisfies
+// cond.
+func waitForSnapshot(t *testing.T, cond func(t *templateFields) bool) *templateFields {
+	t.Helper()
+	timeout := time.After(30 * time.Second)
+	for {
+		if ti := templateInformation.Load(); cond(ti) {
+			return ti
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("timeout waiting for voting information")
+		}
+	}
+}
+
+// TestReconnect ensures that the last voting information continues to be
+// served, marked as stale, while dcrd is down, and that dcrvotingweb catches
+// up with the blocks it missed and resubscribes to notifications once dcrd
+// is back.
+func TestReconnect(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	h.dcrd.setOnline(false)
+	ti := waitForSnapshot(t, func(t *templateFields) bool {
+		return !t.BackendAvailable
+	})
+	if ti.BlockHeight != 300 || ti.BackendError == "" {
+		t.Fatalf("height %d, error %q while dcrd is down", ti.BlockHeight,
+			ti.BackendError)
+	}
+	h.assertPage("Unable to reach dcrd", "Block #300")
+
+	var status apiStatus
+	h.getJSON("/api/v1/status", &status)
+	if status.BackendAvailable || status.BackendError == "" ||
+		status.LastUpdate == nil || status.SecondsSinceUpdate == nil {
+		t.Fatalf("unexpected status while dcrd is down: %+v", status)
+	}
+	h.get("/api/v1/pow", http.StatusOK)
+
+	// Blocks mined while dcrd is down are never notified.
+	h.chain.mineTo(400, 12, 11, votes(5, 12, 0))
+	h.dcrd.setOnline(true)
+	h.waitForHeight(400)
+
+	ti = templateInformation.Load()
+	if !ti.BackendAvailable || ti.BackendError != "" {
+		t.Fatalf("dcrd unavailable after reconnecting: %q", ti.BackendError)
+	}
+	page := string(h.get("/", http.StatusOK))
+	if strings.Contains(page, "Unable to reach dcrd") {
+		t.Errorf("home page reports dcrd unavailable after reconnecting")
+	}
+
+	h.mineTo(450, 12, 11, votes(5, 12, 0))
+	if n := len(h.dcrd.requestsOf("notifyblocks")); n != 2 {
+		t.Errorf("%d notifyblocks requests, want 2", n)
+	}
+}
+
+// TestStartWithDcrdUnavailable ensures that dcrvotingweb serves a page
+// explaining that dcrd is unavailable when it can not connect at startup, and
+// serves the voting information once it does.
+func TestStartWithDcrdUnavailable(t *testing.T) {
+	h := startTestHarness(t, upgradingChain(),
+		filepath.Join(t.TempDir(), voteCacheFilename), false)
+
+	waitForSnapshot(t, func(t *templateFields) bool {
+		return t.BackendError != ""
+	})
+	h.assertPage("Unable to reach dcrd",
+		"Voting information will be shown once a connection has been established")
+
+	var status apiStatus
+	h.getJSON("/api/v1/status", &status)
+	if status.BackendAvailable || status.LastUpdate != nil {
+		t.Fatalf("unexpected status before connecting: %+v", status)
+	}
+	for _, path := range []string{"/api/v1/pow", "/api/v1/stakeversionintervals",
+		"/api/v1/agendas", "/api/v1/agendas/example"} {
+		h.get(path, http.StatusServiceUnavailable)
+	}
+
+	h.dcrd.setOnline(true)
+	h.waitForHeight(300)
+	h.assertPage("Block #300")
+	h.getJSON("/api/v1/status", &status)
+	if !status.BackendAvailable || status.LastUpdate == nil {
+		t.Fatalf("unexpected status after connecting: %+v", status)
+	}
+	h.get("/api/v1/pow", http.StatusOK)
+}
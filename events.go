@@ -0,0 +1,185 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// maxEventStreams limits the number of concurrent event streams, each
+	// of which holds a connection open indefinitely.
+	maxEventStreams = 1000
+
+	// eventKeepAliveInterval is how often a comment is sent on an idle
+	// event stream, so that proxies do not close it and a client which has
+	// gone away is detected.  It must be less than writeTimeout.
+	eventKeepAliveInterval = 30 * time.Second
+)
+
+// apiUpdateEvent is the data of the update events sent by /api/v1/events.
+type apiUpdateEvent struct {
+	// UpdateID identifies the voting information, and is also the event ID.
+	UpdateID         string         `json:"update_id"`
+	BlockHeight      int64          `json:"block_height"`
+	Phase            string         `json:"phase"`
+	BackendAvailable bool           `json:"backend_available"`
+	LastUpdate       *time.Time     `json:"last_update"`
+	Agendas          []apiAgendaRef `json:"agendas"`
+}
+
+// eventBroker wakes the event streams whenever new voting information is
+// published.
+type eventBroker struct {
+	mu      sync.Mutex
+	streams int
+	// changed is closed, and replaced, on each publish.
+	changed chan struct{}
+	// closed is closed when the web server is shutting down, which ends
+	// every stream.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		changed: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// publish notifies every stream that new voting information has been
+// published.
+func (b *eventBroker) publish() {
+	b.mu.Lock()
+	close(b.changed)
+	b.changed = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// close ends every stream, and prevents new ones.
+func (b *eventBroker) close() {
+	b.closeOnce.Do(func() { close(b.closed) })
+}
+
+// wait returns a channel which is closed on the next publish.
+func (b *eventBroker) wait() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.changed
+}
+
+// open registers a new stream, returning false if there are too many.
+func (b *eventBroker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.streams >= maxEventStreams {
+		return false
+	}
+	b.streams++
+	return true
+}
+
+func (b *eventBroker) done() {
+	b.mu.Lock()
+	b.streams--
+	b.mu.Unlock()
+}
+
+// newAPIUpdateEvent returns the update event describing t.
+func newAPIUpdateEvent(t *templateFields) *apiUpdateEvent {
+	agendas := make([]apiAgendaRef, 0, len(t.Agendas))
+	for _, a := range t.Agendas {
+		agendas = append(agendas, apiAgendaRef{
+			ID:          a.ID,
+			VoteVersion: a.VoteVersion,
+			Status:      a.Status,
+		})
+	}
+	event := &apiUpdateEvent{
+		UpdateID:         t.UpdateID(),
+		BlockHeight:      t.BlockHeight,
+		Phase:            t.phase(),
+		BackendAvailable: t.BackendAvailable,
+		Agendas:          agendas,
+	}
+	if t.HasData() {
+		lastUpdate := t.LastUpdate.UTC()
+		event.LastUpdate = &lastUpdate
+	}
+	return event
+}
+
+// apiEvents streams an update event, as server-sent events, each time new
+// voting information is published.  The current voting information is sent
+// when the stream is opened, unless the Last-Event-ID header shows the client
+// already has it.
+func (td *WebUI) apiEvents(w http.ResponseWriter, r *http.Request) {
+	if !td.events.open() {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "too many event streams"})
+		return
+	}
+	defer td.events.done()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Access-Control-Allow-Origin", "*")
+	// Disable response buffering by nginx, which would delay events.
+	h.Set("X-Accel-Buffering", "no")
+
+	// The write deadline is extended before each write, as the stream
+	// outlives the server's write timeout.
+	rc := http.NewResponseController(w)
+	send := func(msg []byte) bool {
+		rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := w.Write(msg); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	if !send([]byte(": connected\n\n")) {
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		// The channel must be obtained before loading the voting
+		// information, so that a publish in between is not missed.
+		changed := td.events.wait()
+		t := td.TemplateData.Load()
+		if id := t.UpdateID(); id != lastID {
+			data, err := json.Marshal(newAPIUpdateEvent(t))
+			if err != nil {
+				return
+			}
+			var msg bytes.Buffer
+			fmt.Fprintf(&msg, "id: %s\nevent: update\ndata: %s\n\n", id, data)
+			if !send(msg.Bytes()) {
+				return
+			}
+			lastID = id
+		}
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if !send([]byte(": keep-alive\n\n")) {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-td.events.closed:
+			return
+		}
+	}
+}
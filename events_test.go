@@ -0,0 +1,191 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sseEvent is a server-sent event.
+type sseEvent struct {
+	id, event, data string
+}
+
+// eventStream is a client of /api/v1/events.
+type eventStream struct {
+	t      *testing.T
+	resp   *http.Response
+	events chan sseEvent
+}
+
+// openEventStream opens /api/v1/events, sending lastEventID as the
+// Last-Event-ID header if it is set.
+func (h *testHarness) openEventStream(lastEventID string) *eventStream {
+	h.t.Helper()
+	req, err := http.NewRequest(http.MethodGet, h.web.URL+"/api/v1/events", nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		h.t.Fatalf("Content-Type %q, want text/event-stream", ct)
+	}
+
+	s := &eventStream{t: h.t, resp: resp, events: make(chan sseEvent)}
+	go func() {
+		defer close(s.events)
+		var e sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				e.id = value
+			case "event":
+				e.event = value
+			case "data":
+				e.data = value
+			case "":
+				if e.event != "" {
+					s.events <- e
+				}
+				e = sseEvent{}
+			}
+		}
+	}()
+	h.t.Cleanup(s.close)
+	return s
+}
+
+func (s *eventStream) close() {
+	s.resp.Body.Close()
+}
+
+// next returns the next update event, or nil if the stream ended.
+func (s *eventStream) next() *apiUpdateEvent {
+	s.t.Helper()
+	select {
+	case e, ok := <-s.events:
+		if !ok {
+			return nil
+		}
+		if e.event != "update" {
+			s.t.Fatalf("event %q, want update", e.event)
+		}
+		var update apiUpdateEvent
+		if err := json.Unmarshal([]byte(e.data), &update); err != nil {
+			s.t.Fatal(err)
+		}
+		if e.id != update.UpdateID {
+			s.t.Fatalf("event ID %q, want %q", e.id, update.UpdateID)
+		}
+		return &update
+	case <-time.After(10 * time.Second):
+		s.t.Fatalf("timeout waiting for event")
+		return nil
+	}
+}
+
+// assertNoEvent asserts that no event is received for a short while.
+func (s *eventStream) assertNoEvent() {
+	s.t.Helper()
+	select {
+	case e := <-s.events:
+		s.t.Fatalf("unexpected event %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestEvents ensures that an update event is streamed for the current voting
+// information, and each time it is updated.
+func TestEvents(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	s := h.openEventStream("")
+	e := s.next()
+	if e.BlockHeight != 300 || e.UpdateID != templateInformation.Load().UpdateID() ||
+		!e.BackendAvailable || e.LastUpdate == nil || e.Phase != "upgrading" {
+		t.Fatalf("initial event %+v", e)
+	}
+	if len(e.Agendas) == 0 {
+		t.Errorf("initial event has no agendas")
+	}
+	s.assertNoEvent()
+
+	h.mineTo(301, 12, 11, votes(5, 12, 0))
+	e = s.next()
+	if e.BlockHeight != 301 || e.UpdateID != templateInformation.Load().UpdateID() {
+		t.Fatalf("event after block %+v", e)
+	}
+
+	// A client which reconnects with the current voting information is
+	// only sent later updates.
+	resumed := h.openEventStream(e.UpdateID)
+	resumed.assertNoEvent()
+	h.mineTo(302, 12, 11, votes(5, 12, 0))
+	if e := resumed.next(); e.BlockHeight != 302 {
+		t.Fatalf("resumed stream event at height %d, want 302", e.BlockHeight)
+	}
+	if e := s.next(); e.BlockHeight != 302 {
+		t.Fatalf("event at height %d, want 302", e.BlockHeight)
+	}
+
+	// Losing dcrd is an update too.
+	h.dcrd.setOnline(false)
+	if e := s.next(); e.BackendAvailable || e.BlockHeight != 302 {
+		t.Fatalf("event after dcrd went offline %+v", e)
+	}
+}
+
+// TestEventsShutdown ensures that event streams end when the web server shuts
+// down, rather than holding it open.
+func TestEventsShutdown(t *testing.T) {
+	public, err := publicFS("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	webUI, err := NewWebUI(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot atomic.Pointer[templateFields]
+	snapshot.Store(&templateFields{})
+	webUI.TemplateData = &snapshot
+	s, stop := startWebServer(t, &config{Listen: []string{"127.0.0.1:0"}}, webUI.router())
+	s.onShutdown(webUI.events.close)
+
+	resp, err := http.Get("http://" + s.listeners[0].Addr().String() + "/api/v1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if resp.StatusCode != http.StatusOK || line != ": connected\n" {
+		t.Fatalf("event stream status %d, first line %q (%v)", resp.StatusCode, line, err)
+	}
+
+	start := time.Now()
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > shutdownTimeout/2 {
+		t.Fatalf("shutdown took %v with an open event stream", elapsed)
+	}
+}
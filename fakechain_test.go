@@ -0,0 +1,550 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/dcrjson/v4"
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/txscript/v4"
+	"github.com/decred/dcrd/wire"
+)
+
+// Vote bits of the yes/no/abstain choices of agendas using the mask 0x06,
+// which is used by the maxtreasuryspend agenda of the test network.
+const (
+	bitsAbstain uint16 = 0x00
+	bitsNo      uint16 = 0x02
+	bitsYes     uint16 = 0x04
+)
+
+// fakeBlock is a block of a fakeChain. Only the data which is required to
+// answer the RPCs made by dcrvotingweb is modeled.
+type fakeBlock struct {
+	header wire.BlockHeader
+	hash   chainhash.Hash
+	votes  []types.VersionBits
+	// tspendVotes are the yes and no votes cast on tspends in the block.
+	tspendVotes map[chainhash.Hash]tspendBallot
+	// tspends are the tspends mined in the block.
+	tspends []*wire.MsgTx
+}
+
+// tspendBallot is a number of yes and no votes on a tspend.
+type tspendBallot struct {
+	yes, no int64
+}
+
+// fakeChain is a scripted, in-memory, block chain which is served to
+// dcrvotingweb by a fakeDcrd.  It is safe for concurrent use.
+type fakeChain struct {
+	params *chaincfg.Params
+
+	mtx sync.Mutex
+	// blocks is the main chain, indexed by height.
+	blocks []*fakeBlock
+	byHash map[chainhash.Hash]*fakeBlock
+	// statuses holds the agenda statuses returned by getvoteinfo, keyed by
+	// vote version and agenda ID.  Agendas without a status are "defined".
+	statuses map[uint32]map[string]string
+	// nonce is set as the nonce of mined blocks.  It is incremented by every
+	// reorganization so that replacement blocks have different hashes.
+	nonce uint32
+
+	// tspends holds every tspend which has been added, keyed by hash, and
+	// mempool those which have not been mined.
+	tspends map[chainhash.Hash]*wire.MsgTx
+	mempool map[chainhash.Hash]*wire.MsgTx
+	// tspendBallots are the votes cast on each tspend in every mined block
+	// inside its voting window.
+	tspendBallots map[chainhash.Hash]tspendBallot
+	// approved holds the tspends in the mempool which are mined in the next
+	// treasury vote interval block.
+	approved map[chainhash.Hash]bool
+}
+
+// testNetParams returns the parameters of the network used by the end-to-end
+// tests.  It is simnet, which has short intervals, with a single deployment of
+// the maxtreasuryspend agenda at vote version 12.
+func testNetParams() *chaincfg.Params {
+	params := *chaincfg.SimNetParams()
+	params.Deployments = map[uint32][]chaincfg.ConsensusDeployment{
+		12: chaincfg.SimNetParams().Deployments[12],
+	}
+	return &params
+}
+
+// newFakeChain returns a fake chain containing only a genesis block.
+func newFakeChain(params *chaincfg.Params) *fakeChain {
+	genesis := &fakeBlock{
+		header: params.GenesisBlock.Header,
+		hash:   params.GenesisBlock.BlockHash(),
+	}
+	return &fakeChain{
+		params:   params,
+		blocks:   []*fakeBlock{genesis},
+		byHash:   map[chainhash.Hash]*fakeBlock{genesis.hash: genesis},
+		statuses: make(map[uint32]map[string]string),
+
+		tspends:       make(map[chainhash.Hash]*wire.MsgTx),
+		mempool:       make(map[chainhash.Hash]*wire.MsgTx),
+		tspendBallots: make(map[chainhash.Hash]tspendBallot),
+		approved:      make(map[chainhash.Hash]bool),
+	}
+}
+
+// votes returns n votes of the provided version and bits.
+func votes(n int, version uint32, bits uint16) []types.VersionBits {
+	vs := make([]types.VersionBits, n)
+	for i := range vs {
+		vs[i] = types.VersionBits{Version: version, Bits: bits}
+	}
+	return vs
+}
+
+// concatVotes joins multiple sets of votes into one.
+func concatVotes(sets ...[]types.VersionBits) []types.VersionBits {
+	var all []types.VersionBits
+	for _, set := range sets {
+		all = append(all, set...)
+	}
+	return all
+}
+
+// mineTo extends the main chain up to and including the provided height. Each
+// new block has the provided block and stake versions and, once the stake
+// validation height has been reached, includes the provided votes.
+func (c *fakeChain) mineTo(height int64, blockVersion int32, stakeVersion uint32,
+	blockVotes []types.VersionBits) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for tip := c.blocks[len(c.blocks)-1]; int64(tip.header.Height) < height; tip = c.blocks[len(c.blocks)-1] {
+		nextHeight := tip.header.Height + 1
+		b := &fakeBlock{
+			header: wire.BlockHeader{
+				Version:      blockVersion,
+				PrevBlock:    tip.hash,
+				StakeVersion: stakeVersion,
+				Height:       nextHeight,
+				Nonce:        c.nonce,
+				Timestamp: tip.header.Timestamp.Add(
+					c.params.TargetTimePerBlock).Truncate(time.Second),
+			},
+		}
+		if int64(nextHeight) >= c.params.StakeValidationHeight {
+			b.votes = blockVotes
+			b.header.Voters = uint16(len(blockVotes))
+		}
+		c.mineTSpends(b)
+		b.hash = b.header.BlockHash()
+		c.blocks = append(c.blocks, b)
+		c.byHash[b.hash] = b
+	}
+}
+
+// disconnectTo removes every block above the provided height from the main
+// chain, and returns them ordered newest to oldest.  Removed blocks can still
+// be looked up by hash, as with dcrd.
+func (c *fakeChain) disconnectTo(height int64) []*fakeBlock {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var removed []*fakeBlock
+	for i := len(c.blocks) - 1; int64(i) > height; i-- {
+		removed = append(removed, c.blocks[i])
+		// Like dcrd, tspends mined in disconnected blocks return to
+		// the mempool.
+		for _, tx := range c.blocks[i].tspends {
+			c.mempool[tx.TxHash()] = tx
+		}
+	}
+	c.blocks = c.blocks[:height+1]
+	c.nonce++
+	return removed
+}
+
+// setStatus sets the status of an agenda returned by getvoteinfo.
+func (c *fakeChain) setStatus(version uint32, agendaID, status string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.statuses[version] == nil {
+		c.statuses[version] = make(map[string]string)
+	}
+	c.statuses[version][agendaID] = status
+}
+
+// tip returns the current main chain tip.
+func (c *fakeChain) tip() *fakeBlock {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.blocks[len(c.blocks)-1]
+}
+
+// blockRange returns the main chain blocks from startHeight to endHeight,
+// inclusive.
+func (c *fakeChain) blockRange(startHeight, endHeight int64) []*fakeBlock {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if endHeight >= int64(len(c.blocks)) {
+		endHeight = int64(len(c.blocks)) - 1
+	}
+	if startHeight < 0 || startHeight > endHeight {
+		return nil
+	}
+	return append([]*fakeBlock(nil), c.blocks[startHeight:endHeight+1]...)
+}
+
+// blockHash implements getblockhash.
+func (c *fakeChain) blockHash(height int64) (*chainhash.Hash, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if height < 0 || height >= int64(len(c.blocks)) {
+		return nil, dcrjson.NewRPCError(dcrjson.ErrRPCOutOfRange,
+			fmt.Sprintf("Block number out of range: %d", height))
+	}
+	return &c.blocks[height].hash, nil
+}
+
+// blockHeader implements getblockheader.
+func (c *fakeChain) blockHeader(hash *chainhash.Hash) (*wire.BlockHeader, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	b, ok := c.byHash[*hash]
+	if !ok {
+		return nil, dcrjson.NewRPCError(dcrjson.ErrRPCBlockNotFound,
+			fmt.Sprintf("Block not found: %v", hash))
+	}
+	return &b.header, nil
+}
+
+// stakeVersions implements getstakeversions.  Like dcrd, it walks back from
+// the requested block through its ancestors, so orphaned blocks are supported.
+func (c *fakeChain) stakeVersions(hash *chainhash.Hash, count int32) (*types.GetStakeVersionsResult, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// Like dcrd, unknown blocks are reported as an internal error.
+	b, ok := c.byHash[*hash]
+	if !ok {
+		return nil, dcrjson.NewRPCError(dcrjson.ErrRPCInternal.Code,
+			fmt.Sprintf("Could not obtain stake versions: block not found: %v", hash))
+	}
+
+	// Ordered newest to oldest.
+	result := &types.GetStakeVersionsResult{
+		StakeVersions: make([]types.StakeVersions, 0, count),
+	}
+	for i := int32(0); i < count && b != nil; i++ {
+		result.StakeVersions = append(result.StakeVersions, types.StakeVersions{
+			Hash:         b.hash.String(),
+			Height:       int64(b.header.Height),
+			BlockVersion: b.header.Version,
+			StakeVersion: b.header.StakeVersion,
+			Votes:        append([]types.VersionBits{}, b.votes...),
+		})
+		if b.header.Height == 0 {
+			break
+		}
+		b = c.byHash[b.header.PrevBlock]
+	}
+	return result, nil
+}
+
+// calcWantHeight returns the height of the final block of the stake version
+// interval prior to the one containing height, in the same way as dcrd.
+func calcWantHeight(stakeValidationHeight, interval, height int64) int64 {
+	intervalOffset := stakeValidationHeight % interval
+	adjustedHeight := height - intervalOffset - 1
+	return (adjustedHeight - ((adjustedHeight + 1) % interval)) + intervalOffset
+}
+
+// versionCounts converts a map of version counts to a slice ordered by
+// version.
+func versionCounts(counts map[uint32]uint32) []types.VersionCount {
+	vcs := make([]types.VersionCount, 0, len(counts))
+	for v, n := range counts {
+		vcs = append(vcs, types.VersionCount{Version: v, Count: n})
+	}
+	sort.Slice(vcs, func(i, j int) bool { return vcs[i].Version < vcs[j].Version })
+	return vcs
+}
+
+// stakeVersionInfo implements getstakeversioninfo, reproducing the interval
+// boundaries reported by dcrd: the first interval is the current one and ends
+// at the tip, while older intervals have an exclusive end height.
+func (c *fakeChain) stakeVersionInfo(count int32) *types.GetStakeVersionInfoResult {
+	tip := c.tip()
+	interval := c.params.StakeVersionInterval
+
+	result := &types.GetStakeVersionInfoResult{
+		CurrentHeight: int64(tip.header.Height),
+		Hash:          tip.hash.String(),
+	}
+
+	startHeight := int64(tip.header.Height)
+	endHeight := calcWantHeight(c.params.StakeValidationHeight, interval, startHeight) + 1
+	lastHeight := startHeight
+	for i := int32(0); i < count; i++ {
+		numBlocks := startHeight - endHeight
+		if numBlocks <= 0 {
+			break
+		}
+
+		posVersions := make(map[uint32]uint32)
+		voteVersions := make(map[uint32]uint32)
+		for _, b := range c.blockRange(endHeight, lastHeight) {
+			posVersions[b.header.StakeVersion]++
+			for _, v := range b.votes {
+				voteVersions[v.Version]++
+			}
+		}
+		result.Intervals = append(result.Intervals, types.VersionInterval{
+			StartHeight:  endHeight,
+			EndHeight:    startHeight,
+			PoSVersions:  versionCounts(posVersions),
+			VoteVersions: versionCounts(voteVersions),
+		})
+
+		endHeight -= interval
+		startHeight = endHeight + interval
+		lastHeight = startHeight - 1
+	}
+
+	return result
+}
+
+// voteInfo implements getvoteinfo.
+func (c *fakeChain) voteInfo(version uint32) (*types.GetVoteInfoResult, error) {
+	deployments, ok := c.params.Deployments[version]
+	if !ok {
+		return nil, dcrjson.NewRPCError(dcrjson.ErrRPCInvalidParameter,
+			fmt.Sprintf("%d: unrecognized vote version", version))
+	}
+
+	tip := c.tip()
+	height := int64(tip.header.Height)
+	rci := int64(c.params.RuleChangeActivationInterval)
+	startHeight := height - (height-c.params.StakeValidationHeight)%rci
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	result := &types.GetVoteInfoResult{
+		CurrentHeight: height,
+		StartHeight:   startHeight,
+		EndHeight:     startHeight + rci - 1,
+		Hash:          tip.hash.String(),
+		VoteVersion:   version,
+		Quorum:        c.params.RuleChangeActivationQuorum,
+	}
+	for _, d := range deployments {
+		status := c.statuses[version][d.Vote.Id]
+		if status == "" {
+			status = "defined"
+		}
+		agenda := types.Agenda{
+			ID:          d.Vote.Id,
+			Description: d.Vote.Description,
+			Mask:        d.Vote.Mask,
+			StartTime:   d.StartTime,
+			ExpireTime:  d.ExpireTime,
+			Status:      status,
+		}
+		for _, choice := range d.Vote.Choices {
+			agenda.Choices = append(agenda.Choices, types.Choice{
+				ID:          choice.Id,
+				Description: choice.Description,
+				Bits:        choice.Bits,
+				IsAbstain:   choice.IsAbstain,
+				IsNo:        choice.IsNo,
+			})
+		}
+		result.Agendas = append(result.Agendas, agenda)
+	}
+	return result, nil
+}
+
+// tspendWindow returns the voting window of a tspend, in the same way as dcrd:
+// it ends two blocks before the expiry, and starts the vote interval
+// multiplier number of treasury vote intervals before that.
+func (c *fakeChain) tspendWindow(tx *wire.MsgTx) (start, end int64) {
+	end = int64(tx.Expiry) - 2
+	start = end - int64(c.params.TreasuryVoteInterval*c.params.TreasuryVoteIntervalMultiplier)
+	return start, end
+}
+
+// newTSpendTx returns a tspend paying amount atoms with the provided expiry.
+// The nonce randomizes its hash.
+func newTSpendTx(expiry uint32, amount int64, nonce byte) *wire.MsgTx {
+	sigScript := []byte{txscript.OP_DATA_64}
+	sigScript = append(sigScript, make([]byte, 64)...)
+	sigScript = append(sigScript, txscript.OP_DATA_33, 0x02)
+	sigScript = append(sigScript, make([]byte, 32)...)
+	sigScript = append(sigScript, txscript.OP_TSPEND)
+
+	nullData := append([]byte{txscript.OP_RETURN, txscript.OP_DATA_32}, make([]byte, 32)...)
+	nullData[2] = nonce
+	payout := []byte{txscript.OP_TGEN, txscript.OP_DUP, txscript.OP_HASH160,
+		txscript.OP_DATA_20}
+	payout = append(payout, make([]byte, 20)...)
+	payout = append(payout, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)
+
+	tx := wire.NewMsgTx()
+	tx.Version = wire.TxVersionTreasury
+	tx.Expiry = expiry
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex, wire.TxTreeRegular),
+		ValueIn:          amount,
+		SignatureScript:  sigScript,
+	})
+	tx.AddTxOut(wire.NewTxOut(0, nullData))
+	tx.AddTxOut(wire.NewTxOut(amount, payout))
+	return tx
+}
+
+// addTSpend adds a tspend to the mempool and returns its hash.
+func (c *fakeChain) addTSpend(tx *wire.MsgTx) chainhash.Hash {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	hash := tx.TxHash()
+	c.tspends[hash] = tx
+	c.mempool[hash] = tx
+	return hash
+}
+
+// setTSpendBallot sets the votes cast on a tspend in every block mined from
+// now on inside its voting window.
+func (c *fakeChain) setTSpendBallot(hash chainhash.Hash, yes, no int64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.tspendBallots[hash] = tspendBallot{yes: yes, no: no}
+}
+
+// approveTSpend causes a tspend in the mempool to be mined in the next
+// treasury vote interval block.
+func (c *fakeChain) approveTSpend(hash chainhash.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.approved[hash] = true
+}
+
+// mineTSpends adds the votes on tspends, and the approved tspends, to a block
+// being mined.  The caller must hold c.mtx.
+func (c *fakeChain) mineTSpends(b *fakeBlock) {
+	height := int64(b.header.Height)
+	for hash, tx := range c.mempool {
+		start, end := c.tspendWindow(tx)
+		if ballot, ok := c.tspendBallots[hash]; ok && height >= start && height < end {
+			if b.tspendVotes == nil {
+				b.tspendVotes = make(map[chainhash.Hash]tspendBallot)
+			}
+			b.tspendVotes[hash] = ballot
+		}
+	}
+	if height%int64(c.params.TreasuryVoteInterval) != 0 {
+		return
+	}
+	for hash := range c.approved {
+		if tx, ok := c.mempool[hash]; ok && height < int64(tx.Expiry) {
+			b.tspends = append(b.tspends, tx)
+			delete(c.mempool, hash)
+		}
+	}
+}
+
+// block implements getblock.
+func (c *fakeChain) block(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	b, ok := c.byHash[*hash]
+	if !ok {
+		return nil, fmt.Errorf("block not found: %v", hash)
+	}
+	return &wire.MsgBlock{
+		Header:        b.header,
+		STransactions: b.tspends,
+	}, nil
+}
+
+// rawTransaction implements getrawtransaction for tspends.
+func (c *fakeChain) rawTransaction(hash *chainhash.Hash) (*wire.MsgTx, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	tx, ok := c.tspends[*hash]
+	if !ok {
+		return nil, fmt.Errorf("no information available about transaction %v", hash)
+	}
+	return tx, nil
+}
+
+// mempoolTSpends implements getrawmempool for tspends.
+func (c *fakeChain) mempoolTSpends() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	hashes := make([]string, 0, len(c.mempool))
+	for hash := range c.mempool {
+		hashes = append(hashes, hash.String())
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// treasurySpendVotes implements gettreasuryspendvotes.  Like dcrd, the votes
+// cast in the voting window up to and including the requested block, or the
+// tip if it is nil, are counted for the requested tspends, or for those in the
+// mempool if none are requested.
+func (c *fakeChain) treasurySpendVotes(blockHash *chainhash.Hash, tspends []chainhash.Hash) (*types.GetTreasurySpendVotesResult, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	b := c.blocks[len(c.blocks)-1]
+	if blockHash != nil {
+		var ok bool
+		if b, ok = c.byHash[*blockHash]; !ok {
+			return nil, fmt.Errorf("block not found: %v", blockHash)
+		}
+	}
+	if tspends == nil {
+		for hash := range c.mempool {
+			tspends = append(tspends, hash)
+		}
+	}
+
+	result := &types.GetTreasurySpendVotesResult{
+		Hash:   b.hash.String(),
+		Height: int64(b.header.Height),
+		Votes:  make([]types.TreasurySpendVotes, 0, len(tspends)),
+	}
+	for _, hash := range tspends {
+		tx, ok := c.tspends[hash]
+		if !ok {
+			return nil, fmt.Errorf("tspend %v not found", hash)
+		}
+		start, end := c.tspendWindow(tx)
+		votes := types.TreasurySpendVotes{
+			Hash:      hash.String(),
+			Expiry:    int64(tx.Expiry),
+			VoteStart: start,
+			VoteEnd:   end,
+		}
+		for vb := b; int64(vb.header.Height) >= start; vb = c.byHash[vb.header.PrevBlock] {
+			if int64(vb.header.Height) < end {
+				votes.YesVotes += vb.tspendVotes[hash].yes
+				votes.NoVotes += vb.tspendVotes[hash].no
+			}
+		}
+		result.Votes = append(result.Votes, votes)
+	}
+	return result, nil
+}
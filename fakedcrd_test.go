@@ -0,0 +1,469 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrjson/v4"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	fakeRPCUser = "user"
+	fakeRPCPass = "pass"
+)
+
+// rpcRequest is a JSON-RPC request received from a client.
+type rpcRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     json.RawMessage   `json:"id"`
+}
+
+// rpcError is the error object of a failed JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcMessage is a JSON-RPC response or notification sent to a client.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  []any           `json:"params,omitempty"`
+	Result  any             `json:"result"`
+	Error   *rpcError       `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// fakeDcrdConn is a single websocket client of a fakeDcrd.
+type fakeDcrdConn struct {
+	ws *websocket.Conn
+
+	// writeMtx serializes writes of responses and notifications.
+	writeMtx sync.Mutex
+	// notifyBlocks is set once the client has called notifyblocks.
+	notifyBlocks bool
+}
+
+func (c *fakeDcrdConn) send(msg *rpcMessage) error {
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	return c.ws.WriteJSON(msg)
+}
+
+// fakeDcrd is an in-process dcrd websocket JSON-RPC server which serves a
+// fakeChain.  It implements the subset of RPCs used by dcrvotingweb, along
+// with blockconnected notifications.
+type fakeDcrd struct {
+	t      *testing.T
+	chain  *fakeChain
+	server *httptest.Server
+
+	mtx   sync.Mutex
+	conns map[*fakeDcrdConn]struct{}
+	// notifiedHeight is the height of the last block connected notification
+	// which was sent.
+	notifiedHeight int64
+	// failing holds the methods which currently return an error.
+	failing map[string]bool
+	// stalled holds the methods whose requests are not answered until the
+	// channel is closed.
+	stalled map[string]chan struct{}
+	// requests are all of the requests received, in order.
+	requests []*rpcRequest
+	// offline is set while the fake dcrd refuses connections, simulating a
+	// dcrd which is down.
+	offline bool
+}
+
+// newFakeDcrd starts a fake dcrd serving the provided chain.  It is stopped
+// when the test completes.
+func newFakeDcrd(t *testing.T, chain *fakeChain) *fakeDcrd {
+	d := &fakeDcrd{
+		t:              t,
+		chain:          chain,
+		conns:          make(map[*fakeDcrdConn]struct{}),
+		notifiedHeight: int64(chain.tip().header.Height),
+		failing:        make(map[string]bool),
+		stalled:        make(map[string]chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", d.handleWebsocket)
+	d.server = httptest.NewServer(mux)
+	t.Cleanup(d.close)
+	return d
+}
+
+// host returns the host:port the fake dcrd is listening on.
+func (d *fakeDcrd) host() string {
+	return strings.TrimPrefix(d.server.URL, "http://")
+}
+
+// setOnline simulates dcrd stopping or starting.  While offline all clients
+// are disconnected and new connections are refused.  Blocks mined while
+// offline are never notified, as a restarted dcrd would not know about the
+// previous clients.
+func (d *fakeDcrd) setOnline(online bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.offline = !online
+	if d.offline {
+		for c := range d.conns {
+			c.ws.Close()
+		}
+	} else {
+		d.notifiedHeight = int64(d.chain.tip().header.Height)
+	}
+}
+
+// close disconnects all clients and stops the server.
+func (d *fakeDcrd) close() {
+	d.mtx.Lock()
+	for c := range d.conns {
+		c.ws.Close()
+	}
+	d.mtx.Unlock()
+	d.server.Close()
+}
+
+func (d *fakeDcrd) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || user != fakeRPCUser || pass != fakeRPCPass {
+		http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
+		return
+	}
+
+	d.mtx.Lock()
+	offline := d.offline
+	d.mtx.Unlock()
+	if offline {
+		http.Error(w, "503 Service Unavailable.", http.StatusServiceUnavailable)
+		return
+	}
+
+	upgrader := websocket.Upgrader{}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		d.t.Logf("fake dcrd: websocket upgrade failed: %v", err)
+		return
+	}
+	c := &fakeDcrdConn{ws: ws}
+	d.mtx.Lock()
+	if d.offline {
+		d.mtx.Unlock()
+		ws.Close()
+		return
+	}
+	d.conns[c] = struct{}{}
+	d.mtx.Unlock()
+
+	defer func() {
+		d.mtx.Lock()
+		delete(d.conns, c)
+		d.mtx.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		var req rpcRequest
+		if err := ws.ReadJSON(&req); err != nil {
+			return
+		}
+		result, err := d.handleRequest(c, &req)
+		resp := &rpcMessage{Result: result, ID: req.ID}
+		if err != nil {
+			resp.Result = nil
+			// Errors which are not dcrd JSON-RPC errors are
+			// reported with the generic code.
+			resp.Error = &rpcError{Code: int(dcrjson.ErrRPCMisc), Message: err.Error()}
+			var rpcErr *dcrjson.RPCError
+			if errors.As(err, &rpcErr) {
+				resp.Error = &rpcError{Code: int(rpcErr.Code), Message: rpcErr.Message}
+			}
+		}
+		if err := c.send(resp); err != nil {
+			return
+		}
+	}
+}
+
+// param decodes the i'th parameter of a request into v.
+func param(req *rpcRequest, i int, v any) error {
+	if i >= len(req.Params) {
+		return fmt.Errorf("%s: missing parameter %d", req.Method, i)
+	}
+	return json.Unmarshal(req.Params[i], v)
+}
+
+// hashParam decodes the i'th parameter of a request as a block hash.
+func hashParam(req *rpcRequest, i int) (*chainhash.Hash, error) {
+	var s string
+	if err := param(req, i, &s); err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(s)
+}
+
+// setFailing sets whether requests of the provided method return an error.
+func (d *fakeDcrd) setFailing(method string, fail bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.failing[method] = fail
+}
+
+// stall holds requests of the provided method until the returned function is
+// called, or the test completes.
+func (d *fakeDcrd) stall(method string) (release func()) {
+	stall := make(chan struct{})
+	d.mtx.Lock()
+	d.stalled[method] = stall
+	d.mtx.Unlock()
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			d.mtx.Lock()
+			delete(d.stalled, method)
+			d.mtx.Unlock()
+			close(stall)
+		})
+	}
+	d.t.Cleanup(release)
+	return release
+}
+
+// requestsOf returns every request of the provided method received so far.
+func (d *fakeDcrd) requestsOf(method string) []*rpcRequest {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	var reqs []*rpcRequest
+	for _, req := range d.requests {
+		if req.Method == method {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+func (d *fakeDcrd) handleRequest(c *fakeDcrdConn, req *rpcRequest) (any, error) {
+	d.mtx.Lock()
+	d.requests = append(d.requests, req)
+	fail := d.failing[req.Method]
+	stall := d.stalled[req.Method]
+	d.mtx.Unlock()
+	if stall != nil {
+		<-stall
+	}
+	if fail {
+		return nil, fmt.Errorf("%s: injected failure", req.Method)
+	}
+
+	switch req.Method {
+	case "notifyblocks":
+		d.mtx.Lock()
+		c.notifyBlocks = true
+		d.mtx.Unlock()
+		return nil, nil
+
+	case "getbestblockhash":
+		return d.chain.tip().hash.String(), nil
+
+	case "getblockhash":
+		var height int64
+		if err := param(req, 0, &height); err != nil {
+			return nil, err
+		}
+		hash, err := d.chain.blockHash(height)
+		if err != nil {
+			return nil, err
+		}
+		return hash.String(), nil
+
+	case "getblockheader":
+		hash, err := hashParam(req, 0)
+		if err != nil {
+			return nil, err
+		}
+		header, err := d.chain.blockHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+		b, err := header.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(b), nil
+
+	case "getstakeversions":
+		hash, err := hashParam(req, 0)
+		if err != nil {
+			return nil, err
+		}
+		var count int32
+		if err := param(req, 1, &count); err != nil {
+			return nil, err
+		}
+		return d.chain.stakeVersions(hash, count)
+
+	case "getstakeversioninfo":
+		count := int32(1)
+		if len(req.Params) > 0 {
+			if err := param(req, 0, &count); err != nil {
+				return nil, err
+			}
+		}
+		return d.chain.stakeVersionInfo(count), nil
+
+	case "getvoteinfo":
+		var version uint32
+		if err := param(req, 0, &version); err != nil {
+			return nil, err
+		}
+		return d.chain.voteInfo(version)
+
+	case "getblock":
+		hash, err := hashParam(req, 0)
+		if err != nil {
+			return nil, err
+		}
+		block, err := d.chain.block(hash)
+		if err != nil {
+			return nil, err
+		}
+		b, err := block.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(b), nil
+
+	case "getrawtransaction":
+		hash, err := hashParam(req, 0)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := d.chain.rawTransaction(hash)
+		if err != nil {
+			return nil, err
+		}
+		b, err := tx.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(b), nil
+
+	case "getrawmempool":
+		var txType string
+		if len(req.Params) > 1 {
+			if err := param(req, 1, &txType); err != nil {
+				return nil, err
+			}
+		}
+		if txType != "tspend" {
+			return nil, fmt.Errorf("getrawmempool: unsupported type %q", txType)
+		}
+		return d.chain.mempoolTSpends(), nil
+
+	case "gettreasuryspendvotes":
+		var block string
+		var tspends []string
+		if len(req.Params) > 0 {
+			if err := param(req, 0, &block); err != nil {
+				return nil, err
+			}
+		}
+		if len(req.Params) > 1 {
+			if err := param(req, 1, &tspends); err != nil {
+				return nil, err
+			}
+		}
+		var blockHash *chainhash.Hash
+		if block != "" {
+			var err error
+			if blockHash, err = chainhash.NewHashFromStr(block); err != nil {
+				return nil, err
+			}
+		}
+		var hashes []chainhash.Hash
+		for _, s := range tspends {
+			hash, err := chainhash.NewHashFromStr(s)
+			if err != nil {
+				return nil, err
+			}
+			hashes = append(hashes, *hash)
+		}
+		return d.chain.treasurySpendVotes(blockHash, hashes)
+
+	default:
+		return nil, fmt.Errorf("method %q not found", req.Method)
+	}
+}
+
+// broadcast sends a notification to every client which has subscribed to
+// block notifications.  The caller must hold d.mtx.
+func (d *fakeDcrd) broadcast(ntfn *rpcMessage) {
+	for c := range d.conns {
+		if !c.notifyBlocks {
+			continue
+		}
+		if err := c.send(ntfn); err != nil {
+			d.t.Logf("fake dcrd: failed to send notification: %v", err)
+		}
+	}
+}
+
+// reorg disconnects every block above the provided height from the chain,
+// sending a blockdisconnected notification for each.
+func (d *fakeDcrd) reorg(height int64) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for _, b := range d.chain.disconnectTo(height) {
+		header, err := b.header.Bytes()
+		if err != nil {
+			d.t.Fatalf("fake dcrd: failed to serialize header: %v", err)
+		}
+		d.broadcast(&rpcMessage{
+			JSONRPC: "1.0",
+			Method:  "blockdisconnected",
+			Params:  []any{hex.EncodeToString(header)},
+			ID:      json.RawMessage("null"),
+		})
+	}
+	d.notifiedHeight = height
+}
+
+// notifyConnected sends a blockconnected notification, to every client which
+// has subscribed to them, for each block mined since the previous call.
+func (d *fakeDcrd) notifyConnected() {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	tipHeight := int64(d.chain.tip().header.Height)
+	for _, b := range d.chain.blockRange(d.notifiedHeight+1, tipHeight) {
+		header, err := b.header.Bytes()
+		if err != nil {
+			d.t.Fatalf("fake dcrd: failed to serialize header: %v", err)
+		}
+		d.broadcast(&rpcMessage{
+			JSONRPC: "1.0",
+			Method:  "blockconnected",
+			Params:  []any{hex.EncodeToString(header), []string{}},
+			ID:      json.RawMessage("null"),
+		})
+	}
+	d.notifiedHeight = tipHeight
+}
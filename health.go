@@ -0,0 +1,118 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// maxBlocksBehind is the number of blocks the voting information may trail
+// dcrd's main chain tip while dcrvotingweb is still ready.  It allows for the
+// update which is computed whenever a block is connected.
+const maxBlocksBehind = 1
+
+// dcrdTipHeight is the height of dcrd's main chain tip according to the most
+// recent block notification, or the catch up after connecting to dcrd.  It is
+// zero until dcrd has been connected.
+var dcrdTipHeight atomic.Int64
+
+// readinessCheck is a single condition of readiness.
+type readinessCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// readiness is the response body of /readyz.
+type readiness struct {
+	Ready       bool       `json:"ready"`
+	BlockHeight int64      `json:"block_height"`
+	DcrdHeight  int64      `json:"dcrd_height"`
+	LastUpdate  *time.Time `json:"last_update,omitempty"`
+	// Checks are every condition of readiness, in a fixed order.  A reason
+	// is included for each condition which is not met.
+	Checks []readinessCheck `json:"checks"`
+}
+
+// checkReadiness returns whether the voting information t can be served.  It
+// must be computed from a connected dcrd, and be for a block no more than
+// maxBlocksBehind behind dcrd's tip at dcrdHeight.  The voting information is
+// only updated when a block is connected, so its age depends on the time
+// between blocks rather than on whether it is current, and it is only checked
+// to be no older than maxAge when that is nonzero.
+func checkReadiness(t *templateFields, dcrdHeight int64, maxAge time.Duration,
+	now time.Time) *readiness {
+
+	r := &readiness{
+		BlockHeight: t.BlockHeight,
+		DcrdHeight:  dcrdHeight,
+	}
+	if t.HasData() {
+		lastUpdate := t.LastUpdate.UTC()
+		r.LastUpdate = &lastUpdate
+	}
+
+	connected := readinessCheck{Name: "dcrd_connected", OK: t.BackendAvailable}
+	if !connected.OK {
+		connected.Reason = "dcrd is unavailable"
+		if t.BackendError != "" {
+			connected.Reason += ": " + t.BackendError
+		}
+	}
+
+	initialUpdate := readinessCheck{Name: "initial_update", OK: t.HasData()}
+	if !initialUpdate.OK {
+		initialUpdate.Reason = "the voting information has not been computed yet"
+	}
+
+	updateAge := readinessCheck{Name: "update_age", OK: t.HasData()}
+	switch age := now.Sub(t.LastUpdate); {
+	case !t.HasData():
+		updateAge.Reason = "there has been no successful update"
+	case maxAge > 0 && age > maxAge:
+		updateAge.OK = false
+		updateAge.Reason = fmt.Sprintf("the last successful update was %v ago, "+
+			"more than the maximum of %v", age.Round(time.Second), maxAge)
+	}
+
+	synced := readinessCheck{Name: "synced", OK: t.HasData()}
+	switch {
+	case !t.HasData():
+		synced.Reason = "the voting information has not been computed yet"
+	case dcrdHeight-t.BlockHeight > maxBlocksBehind:
+		synced.OK = false
+		synced.Reason = fmt.Sprintf("the voting information is for block %d "+
+			"but dcrd's best block is %d", t.BlockHeight, dcrdHeight)
+	}
+
+	r.Checks = []readinessCheck{connected, initialUpdate, updateAge, synced}
+	r.Ready = true
+	for _, c := range r.Checks {
+		r.Ready = r.Ready && c.OK
+	}
+	return r
+}
+
+// healthz reports that the process is alive and serving requests.
+func (td *WebUI) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Status string `json:"status"`
+	}{Status: "ok"})
+}
+
+// readyz reports whether current voting information is being served.  It
+// responds with 503 Service Unavailable when it is not.
+func (td *WebUI) readyz(w http.ResponseWriter, r *http.Request) {
+	ready := checkReadiness(td.TemplateData.Load(), dcrdTipHeight.Load(),
+		td.MaxUpdateAge, time.Now())
+	code := http.StatusOK
+	if !ready.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, ready)
+}
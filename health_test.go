@@ -0,0 +1,128 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	current := templateFields{
+		BlockHeight:      500,
+		BackendAvailable: true,
+		LastUpdate:       now.Add(-10 * time.Minute),
+	}
+
+	tests := []struct {
+		name       string
+		t          templateFields
+		dcrdHeight int64
+		maxAge     time.Duration
+		// failed are the names of the checks which fail, along with a
+		// substring of their reason.
+		failed map[string]string
+	}{{
+		name:       "ready",
+		t:          current,
+		dcrdHeight: 500,
+		maxAge:     time.Hour,
+	}, {
+		name:       "update in progress",
+		t:          current,
+		dcrdHeight: 501,
+		maxAge:     time.Hour,
+	}, {
+		name: "starting",
+		t:    templateFields{BackendError: "connection refused"},
+		failed: map[string]string{
+			"dcrd_connected": "dcrd is unavailable: connection refused",
+			"initial_update": "not been computed",
+			"update_age":     "no successful update",
+			"synced":         "not been computed",
+		},
+	}, {
+		name: "disconnected",
+		t: templateFields{
+			BlockHeight:  500,
+			BackendError: "connection to dcrd lost",
+			LastUpdate:   current.LastUpdate,
+		},
+		dcrdHeight: 500,
+		maxAge:     time.Hour,
+		failed:     map[string]string{"dcrd_connected": "connection to dcrd lost"},
+	}, {
+		name:       "stale",
+		t:          current,
+		dcrdHeight: 500,
+		maxAge:     5 * time.Minute,
+		failed:     map[string]string{"update_age": "10m0s ago, more than the maximum of 5m0s"},
+	}, {
+		name:       "age check disabled",
+		t:          current,
+		dcrdHeight: 500,
+	}, {
+		name:       "behind",
+		t:          current,
+		dcrdHeight: 502,
+		maxAge:     time.Hour,
+		failed:     map[string]string{"synced": "for block 500 but dcrd's best block is 502"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := checkReadiness(&test.t, test.dcrdHeight, test.maxAge, now)
+			if r.Ready != (len(test.failed) == 0) {
+				t.Errorf("ready %v with checks %+v", r.Ready, r.Checks)
+			}
+			if len(r.Checks) != 4 {
+				t.Fatalf("%d checks, want 4", len(r.Checks))
+			}
+			for _, c := range r.Checks {
+				reason, failed := test.failed[c.Name]
+				if c.OK == failed || !strings.Contains(c.Reason, reason) ||
+					(c.OK && c.Reason != "") {
+					t.Errorf("check %+v, want failed %v with reason %q",
+						c, failed, reason)
+				}
+			}
+		})
+	}
+}
+
+// TestReadiness ensures that /readyz reports not ready until dcrd is connected
+// and the voting information has been computed, while /healthz always reports
+// the process is alive.
+func TestReadiness(t *testing.T) {
+	h := startTestHarness(t, upgradingChain(),
+		filepath.Join(t.TempDir(), voteCacheFilename), false)
+	waitForSnapshot(t, func(t *templateFields) bool {
+		return t.BackendError != ""
+	})
+
+	h.get("/healthz", http.StatusOK)
+	var r readiness
+	if err := json.Unmarshal(h.get("/readyz", http.StatusServiceUnavailable), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Ready || r.LastUpdate != nil || r.Checks[0].OK ||
+		!strings.HasPrefix(r.Checks[0].Reason, "dcrd is unavailable") {
+		t.Fatalf("unexpected readiness before connecting: %+v", r)
+	}
+
+	h.dcrd.setOnline(true)
+	h.waitForHeight(300)
+	h.mineTo(310, 12, 11, votes(5, 12, 0))
+	h.getJSON("/readyz", &r)
+	if !r.Ready || r.BlockHeight != 310 || r.DcrdHeight != 310 || r.LastUpdate == nil {
+		t.Fatalf("unexpected readiness after connecting: %+v", r)
+	}
+	h.get("/healthz", http.StatusOK)
+}
@@ -6,17 +6,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/decred/dcrd/rpcclient/v8"
 	"github.com/decred/dcrd/wire"
 )
 
@@ -39,63 +39,90 @@ const (
 )
 
 var (
+	// templateInformation is the most recent snapshot of the voting
+	// information given to the templates. A snapshot is never modified once
+	// it has been stored, so readers must Load it once and use the result.
+	templateInformation atomic.Pointer[templateFields]
+)
 
-	// templateInformation is the template holding the active network
-	// parameters.
-	templateInformation *templateFields
-
-	agendaTitles = map[string]string{
-		"sdiffalgorithm":       "Change PoS Staking Algorithm",
-		"lnsupport":            "Start Lightning Network Support",
-		"lnfeatures":           "Enable Lightning Network Features",
-		"fixlnseqlocks":        "Update Sequence Lock Rules",
-		"headercommitments":    "Enable Block Header Commitments",
-		"treasury":             "Enable Decentralized Treasury",
-		"reverttreasurypolicy": "Revert Treasury Expenditure Policy",
-		"explicitverupgrades":  "Explicit Version Upgrades",
-		"autorevocations":      "Automatic Ticket Revocations",
-		"changesubsidysplit":   "Change PoW/PoS Subsidy Split",
-		"changesubsidysplitr2": "Change PoW/PoS Subsidy Split To 1/89",
-		"blake3pow":            "Change PoW to BLAKE3 and ASERT",
-		"maxtreasuryspend":     "Change Maximum Treasury Expenditure Policy",
+// newBlockVersionMinPercent is the percentage of the blocks in the PoW rolling
+// window which must have a block version before it is considered to be a new
+// block version being upgraded to.  This prevents a handful of blocks with an
+// unexpected version from replacing the upgrade progress.
+const newBlockVersionMinPercent = 5
+
+// expectedBlockVersion returns the block version the PoW upgrade progress is
+// measured against, given the number of blocks of each version in the rolling
+// window.  Releases of dcrd which add deployments for a new vote version also
+// start producing blocks of that version, so unless it is set in the config,
+// the expected block version is the newest deployment vote version.  Blocks
+// produced by a newer dcrd release with deployments unknown to this build are
+// detected by their block version.
+func expectedBlockVersion(blockVersionsCounts map[int32]int64) int32 {
+	if blockVersion != 0 {
+		return blockVersion
 	}
-	longAgendaDescriptions = map[string]string{
-		"sdiffalgorithm":       "Specifies a proposed replacement algorithm for determining the stake difficulty (commonly called the ticket price). This proposal resolves all issues with a new algorithm that adheres to the referenced ideals.",
-		"lnsupport":            "The <a href='https://lightning.network/' target='_blank' rel='noopener noreferrer'>Lightning Network</a> is the most directly useful application of smart contracts to date since it allows for off-chain transactions that optionally settle on-chain. This infrastructure has clear benefits for both scaling and privacy. Decred is optimally positioned for this integration.",
-		"lnfeatures":           "The <a href='https://lightning.network/' target='_blank' rel='noopener noreferrer'>Lightning Network</a> is the most directly useful application of smart contracts to date since it allows for off-chain transactions that optionally settle on-chain. This infrastructure has clear benefits for both scaling and privacy. Decred is optimally positioned for this integration.",
-		"fixlnseqlocks":        "In order to fully support the <a href='https://lightning.network/' target='_blank' rel='noopener noreferrer'>Lightning Network</a>, the current sequence lock consensus rules need to be modified.",
-		"headercommitments":    "Proposed modifications to the Decred block header to increase the security and efficiency of lightweight clients, as well as adding infrastructure to enable future scalability enhancements.",
-		"treasury":             "In May 2019, Decred stakeholders approved the development of <a href='https://proposals.decred.org/proposals/c96290a' target='_blank' rel='noopener noreferrer'>a proposed solution</a> to further decentralize the process of spending from the Decred treasury.",
-		"reverttreasurypolicy": "Change the algorithm used to calculate Treasury spending limits such that it enforces the policy originally approved by stakeholders in the <a href='https://proposals.decred.org/proposals/c96290a' target='_blank' rel='noopener noreferrer'>Decentralized Treasury proposal</a>.",
-		"explicitverupgrades":  "Modifications to Decred transaction and scripting language version enforcement which will simplify deployment and integration of future consensus changes across the Decred ecosystem.",
-		"autorevocations":      "Changes to ticket revocation transactions and block acceptance criteria in order to enable <a href='https://proposals.decred.org/record/e2d7b7d' target='_blank' rel='noopener noreferrer'>automatic ticket revocations</a>, significantly improving the user experience for stakeholders.",
-		"changesubsidysplit":   "<a href='https://proposals.decred.org/record/427e1d4' target='_blank' rel='noopener noreferrer'>Proposal</a> to modify to the block reward subsidy split such that 10% goes to Proof-of-Work and 80% goes to Proof-of-Stake.",
-		"changesubsidysplitr2": "Modify the block reward subsidy split such that 1% goes to Proof-of-Work (PoW) and 89% goes to Proof-of-Stake (PoS). The Treasury subsidy remains at 10%.",
-		"blake3pow":            "<a href='https://proposals.decred.org/record/a8501bc' target='_blank' rel='noopener noreferrer'>Stakeholders signaled</a> to change the Proof-of-Work hash function to BLAKE3. This consensus change will also update the difficulty algorithm to ASERT (Absolutely Scheduled Exponentially weighted Rising Targets).",
-		"maxtreasuryspend":     "<a href='https://proposals.decred.org/record/16a93c7' target='_blank' rel='noopener noreferrer'>Stakeholders signaled</a> to change the maximum expenditure policy of the treasury account to be limited to 4% of the total available treasury per month as defined in <a href='https://github.com/decred/dcps/blob/master/dcp-0013/dcp-0013.mediawiki' target='_blank' rel='noopener noreferrer'>DCP0013</a>.",
+
+	expected := int32(newestDeploymentVersion())
+	return max(expected, newestBlockVersion(blockVersionsCounts))
+}
+
+// newestDeploymentVersion returns the newest vote version with deployments
+// defined by activeNetParams.
+func newestDeploymentVersion() uint32 {
+	var newest uint32
+	for voteVersion := range activeNetParams.Deployments {
+		newest = max(newest, voteVersion)
 	}
-)
+	return newest
+}
+
+// newestBlockVersion returns the newest block version which makes up at least
+// newBlockVersionMinPercent of the PoW rolling window, given the number of
+// blocks of each version in the window.
+func newestBlockVersion(blockVersionsCounts map[int32]int64) int32 {
+	minCount := max(1, int64(activeNetParams.BlockUpgradeNumToCheck)*newBlockVersionMinPercent/100)
+	var newest int32
+	for v, count := range blockVersionsCounts {
+		if v > newest && count >= minCount {
+			newest = v
+		}
+	}
+	return newest
+}
+
+// buildTemplateInformation is called on startup and upon every block notification received.
+// It returns a new snapshot of the voting information at the provided block, leaving
+// prev, the current snapshot, unmodified.
+func buildTemplateInformation(ctx context.Context, chain chainSource, cache *voteCache,
+	prev *templateFields, latestBlockHeader *wire.BlockHeader) (*templateFields, error) {
 
-// updatetemplateInformation is called on startup and upon every block connected notification received.
-func updatetemplateInformation(ctx context.Context, dcrdClient *rpcclient.Client, latestBlockHeader *wire.BlockHeader) {
 	log.Println("Updating vote information")
 
+	// Start from a copy of the current snapshot, which carries over the
+	// fields derived from activeNetParams. Every other field is replaced
+	// below, and published snapshots are never modified.
+	t := *prev
+
 	hash := latestBlockHeader.BlockHash()
 	height := int64(latestBlockHeader.Height)
 
 	log.Printf("Current best block height: %d", height)
 
 	// Set Current block height
-	templateInformation.BlockHeight = height
+	t.BlockHeight = height
 
 	// Request GetStakeVersions to receive information about past block versions.
 	//
 	// Request twice as many, so we can populate the rolling block version window's first
-	stakeVersionResults, err := dcrdClient.GetStakeVersions(ctx, hash.String(),
+	stakeVersionResults, err := chain.GetStakeVersions(ctx, hash.String(),
 		int32(activeNetParams.BlockUpgradeNumToCheck*2))
 	if err != nil {
-		log.Printf("GetStakeVersions error: %v", err)
-		return
+		return nil, fmt.Errorf("GetStakeVersions error: %w", err)
+	}
+	if len(stakeVersionResults.StakeVersions) < int(activeNetParams.BlockUpgradeNumToCheck*2) {
+		return nil, fmt.Errorf("GetStakeVersions returned %d blocks, want %d",
+			len(stakeVersionResults.StakeVersions), activeNetParams.BlockUpgradeNumToCheck*2)
 	}
 	blockVersionsFound := make(map[int32]*blockVersions)
 	blockVersionsHeights := make([]int64, activeNetParams.BlockUpgradeNumToCheck)
@@ -134,8 +161,8 @@ func updatetemplateInformation(ctx context.Context, dcrdClient *rpcclient.Client
 		}
 		elementNum++
 	}
-	templateInformation.BlockVersionsHeights = blockVersionsHeights
-	templateInformation.BlockVersions = blockVersionsFound
+	t.BlockVersionsHeights = blockVersionsHeights
+	t.BlockVersions = blockVersionsFound
 
 	stakeVersionsWindow := stakeVersionResults.StakeVersions[:activeNetParams.BlockUpgradeNumToCheck]
 	blockVersionsCounts := make(map[int32]int64)
@@ -155,26 +182,24 @@ func updatetemplateInformation(ctx context.Context, dcrdClient *rpcclient.Client
 	log.Printf("Most popular block version in the last %d blocks: v%d (%d blocks)",
 		len(stakeVersionsWindow), mostPopularBlockVersion, blockVersionsCounts[mostPopularBlockVersion])
 
-	templateInformation.BlockVersionCurrent = mostPopularBlockVersion
+	t.BlockVersionCurrent = mostPopularBlockVersion
 
-	templateInformation.BlockVersionNext = blockVersion
+	nextBlockVersion := expectedBlockVersion(blockVersionsCounts)
+	t.BlockVersionNext = nextBlockVersion
 
-	blockCountPercentage := 100 * float64(blockVersionsCounts[blockVersion]) / float64(activeNetParams.BlockUpgradeNumToCheck)
-	templateInformation.BlockVersionNextPercentage = blockCountPercentage
+	blockCountPercentage := 100 * float64(blockVersionsCounts[nextBlockVersion]) / float64(activeNetParams.BlockUpgradeNumToCheck)
+	t.BlockVersionNextPercentage = blockCountPercentage
 
-	if blockVersionsCounts[blockVersion] >= int64(activeNetParams.BlockRejectNumRequired) {
-		templateInformation.BlockVersionSuccess = true
-	}
+	t.BlockVersionSuccess = blockVersionsCounts[nextBlockVersion] >= int64(activeNetParams.BlockRejectNumRequired)
 
 	// Voting intervals ((height-4096) mod 2016)
 	blocksIntoStakeVersionInterval := (height - activeNetParams.StakeValidationHeight) %
 		activeNetParams.StakeVersionInterval
 	// Stake versions per block in current voting interval (getstakeversions hash blocksIntoInterval)
-	intervalStakeVersions, err := dcrdClient.GetStakeVersions(ctx, hash.String(),
+	intervalStakeVersions, err := chain.GetStakeVersions(ctx, hash.String(),
 		int32(blocksIntoStakeVersionInterval))
 	if err != nil {
-		log.Printf("GetStakeVersions error: %v", err)
-		return
+		return nil, fmt.Errorf("GetStakeVersions error: %w", err)
 	}
 	// Tally missed votes so far in this interval
 	missedVotesStakeInterval := 0
@@ -183,17 +208,17 @@ func updatetemplateInformation(ctx context.Context, dcrdClient *rpcclient.Client
 	}
 
 	// Vote tallies for previous intervals
-	stakeVersionInfo, err := dcrdClient.GetStakeVersionInfo(ctx, numberOfIntervals)
+	stakeVersionInfo, err := chain.GetStakeVersionInfo(ctx, numberOfIntervals)
 	if err != nil {
-		log.Println(err)
-		return
+		return nil, fmt.Errorf("GetStakeVersionInfo error: %w", err)
 	}
 	numIntervals := len(stakeVersionInfo.Intervals)
 	if numIntervals == 0 {
-		log.Println("StakeVersion info did not return usable information, intervals empty")
-		return
+		return nil, errors.New("StakeVersion info did not return usable information, intervals empty")
 	}
-	templateInformation.StakeVersionsIntervals = stakeVersionInfo.Intervals
+	t.StakeVersionsIntervals = stakeVersionInfo.Intervals
+	t.CurrentSVIVoters = currentIntervalVoters(intervalStakeVersions.StakeVersions,
+		&stakeVersionInfo.Intervals[0])
 
 	minimumNeededVoteVersions := uint32(100)
 	// Hacky way of populating the Vote Version bar graph
@@ -204,13 +229,15 @@ func updatetemplateInformation(ctx context.Context, dcrdClient *rpcclient.Client
 	var stakeVersionIntervalResults []intervalVersionCounts
 	stakeVersionLabels := make([]string, numIntervals)
 	// Oldest to newest interval (charts are left to right)
+	t.SVIParticipation = make([]Participation, numIntervals)
 	for i := 0; i < numIntervals; i++ {
 		interval := &stakeVersionInfo.Intervals[numIntervals-1-i]
 		stakeVersionLabels[i] = fmt.Sprintf("%v - %v", interval.StartHeight, interval.EndHeight-1)
+		t.SVIParticipation[i] = intervalParticipation(interval, i == numIntervals-1)
 		if i == numIntervals-1 {
 			CurrentSVIEndHeightHeight = interval.StartHeight + activeNetParams.StakeVersionInterval - 1
-			templateInformation.CurrentSVIStartHeight = interval.StartHeight
-			templateInformation.CurrentSVIEndHeight = CurrentSVIEndHeightHeight
+			t.CurrentSVIStartHeight = interval.StartHeight
+			t.CurrentSVIEndHeight = CurrentSVIEndHeightHeight
 		}
 	versionloop:
 		for _, versionCount := range interval.VoteVersions {
@@ -233,15 +260,15 @@ func updatetemplateInformation(ctx context.Context, dcrdClient *rpcclient.Client
 	}
 	blocksRemainingStakeInterval := CurrentSVIEndHeightHeight - height
 	timeLeftDuration := activeNetParams.TargetTimePerBlock * time.Duration(blocksRemainingStakeInterval)
-	templateInformation.StakeVersionTimeRemaining = fmtDuration(timeLeftDuration)
+	t.StakeVersionTimeRemaining = fmtDuration(timeLeftDuration)
 	stakeVersionLabels[numIntervals-1] = "Current Interval"
 	currentInterval := stakeVersionInfo.Intervals[0]
 
 	maxPossibleVotes := activeNetParams.StakeVersionInterval*int64(activeNetParams.TicketsPerBlock) -
 		int64(missedVotesStakeInterval)
-	templateInformation.StakeVersionIntervalResults = stakeVersionIntervalResults
-	templateInformation.StakeVersionIntervalLabels = stakeVersionLabels
-	templateInformation.StakeVersionCurrent = latestBlockHeader.StakeVersion
+	t.StakeVersionIntervalResults = stakeVersionIntervalResults
+	t.StakeVersionIntervalLabels = stakeVersionLabels
+	t.StakeVersionCurrent = latestBlockHeader.StakeVersion
 
 	var mostPopularVersion, mostPopularVersionCount uint32
 	for _, stakeVersion := range currentInterval.VoteVersions {
@@ -252,52 +279,68 @@ func updatetemplateInformation(ctx context.Context, dcrdClient *rpcclient.Client
 		}
 	}
 
-	templateInformation.StakeVersionMostPopularPercentage = float64(mostPopularVersionCount) / float64(maxPossibleVotes) * 100
-	templateInformation.StakeVersionMostPopular = mostPopularVersion
+	t.StakeVersionMostPopularPercentage = float64(mostPopularVersionCount) / float64(maxPossibleVotes) * 100
+	t.StakeVersionMostPopular = mostPopularVersion
 
-	svis, err := AllStakeVersionIntervals(ctx, dcrdClient, height)
+	svis, err := AllStakeVersionIntervals(ctx, chain, cache, height)
 	if err != nil {
-		log.Printf("Error stake version intervals: %v", err)
-		return
+		return nil, fmt.Errorf("error getting stake version intervals: %w", err)
 	}
 
-	templateInformation.PosUpgrade.Completed = false
+	t.PosUpgrade = posUpgrade{}
 
 	// Check if upgrade to the latest version occurred in a previous SVI
-	upgradeOccurred, svi := svis.GetStakeVersionUpgradeSVI(svis.MaxVoteVersion)
+	upgradeOccurred, svi, err := svis.GetStakeVersionUpgradeSVI(svis.MaxVoteVersion,
+		blockVersionWindows(ctx, chain, cache, height))
+	if err != nil {
+		return nil, fmt.Errorf("error finding stake version upgrade: %w", err)
+	}
 	if upgradeOccurred {
-		templateInformation.StakeVersionMostPopularPercentage = 100
-		templateInformation.PosUpgrade.Completed = true
-		templateInformation.PosUpgrade.UpgradeInterval = svi
+		t.StakeVersionMostPopularPercentage = 100
+		t.PosUpgrade.Completed = true
+		t.PosUpgrade.UpgradeInterval = svi
 	}
 
 	// Check if Phase Upgrading or Voting
-	if templateInformation.PosUpgrade.Completed && templateInformation.BlockVersionSuccess {
-		templateInformation.IsUpgrading = false
+	if t.PosUpgrade.Completed && t.BlockVersionSuccess {
+		t.IsUpgrading = false
 	} else {
-		templateInformation.IsUpgrading = true
+		t.IsUpgrading = true
 	}
 
-	templateInformation.Agendas, err = agendasForVersions(ctx, dcrdClient, height, svis)
+	t.Agendas, err = agendasForVersions(ctx, chain, cache, height, svis)
 	if err != nil {
-		log.Printf("Error getting agendas: %v", err)
-		return
+		return nil, fmt.Errorf("error getting agendas: %w", err)
 	}
 
+	t.RCIParticipation = votingWindowParticipation(t.Agendas)
+
 	// Assume all agendas have been voted and are pending activation
-	templateInformation.PendingActivation = true
+	t.PendingActivation = true
 
-	templateInformation.RulesActivated = true
+	t.RulesActivated = true
 
-	for _, agenda := range templateInformation.Agendas {
+	for _, agenda := range t.Agendas {
 		// Check to see if all agendas are pending activation
 		if !agenda.IsLockedIn() {
-			templateInformation.PendingActivation = false
+			t.PendingActivation = false
 		}
 		if !agenda.IsActive() {
-			templateInformation.RulesActivated = false
+			t.RulesActivated = false
 		}
 	}
+
+	// Treasury spends are not needed for the rest of the voting
+	// information, so if they can not be updated the previous ones continue
+	// to be shown.
+	tspends, err := treasurySpends(ctx, chain, cache, t.Agendas, height)
+	if err != nil {
+		log.Printf("Failed to update treasury spends: %v", err)
+	} else {
+		t.TSpends = tspends
+	}
+
+	return &t, nil
 }
 
 // main wraps mainCore, which does all the work, because deferred functions do
@@ -312,68 +355,9 @@ func mainCore() int {
 		return 1
 	}
 
-	// Chans for rpccclient notification handlers
-	connectChan := make(chan wire.BlockHeader, 100)
-
-	// Read in current dcrd cert
-	var dcrdCerts []byte
-	if !cfg.DisableTLS {
-		dcrdCerts, err = os.ReadFile(cfg.RPCCert)
-		if err != nil {
-			log.Printf("Failed to read dcrd cert file at %v: %v",
-				cfg.RPCCert, err)
-			return 1
-		}
-	}
-
-	// Set up notification handler that will release ntfns when new blocks connect
-	ntfnHandlersDaemon := rpcclient.NotificationHandlers{
-		OnBlockConnected: func(serializedBlockHeader []byte, _ [][]byte) {
-			var blockHeader wire.BlockHeader
-			errLocal := blockHeader.FromBytes(serializedBlockHeader)
-			if errLocal != nil {
-				log.Printf("Failed to deserialize block header: %v", errLocal)
-				return
-			}
-			log.Printf("Received new block %v (height %d)", blockHeader.BlockHash(),
-				blockHeader.Height)
-			connectChan <- blockHeader
-		},
-	}
-
-	// rpclient configuration
-	connCfgDaemon := &rpcclient.ConnConfig{
-		Host:         cfg.RPCHost,
-		Endpoint:     "ws",
-		User:         cfg.RPCUser,
-		Pass:         cfg.RPCPass,
-		Certificates: dcrdCerts,
-		DisableTLS:   cfg.DisableTLS,
-	}
-
-	log.Printf("Attempting to connect to dcrd RPC %s as user %s "+
-		"using certificate %s", cfg.RPCHost, cfg.RPCUser, cfg.RPCCert)
-	// Attempt to connect rpcclient and daemon
-	dcrdClient, err := rpcclient.New(connCfgDaemon, &ntfnHandlersDaemon)
-	if err != nil {
-		log.Printf("Failed to start dcrd rpcclient: %v", err)
-		return 1
-	}
-	defer func() {
-		log.Printf("Disconnecting from dcrd.")
-		dcrdClient.Disconnect()
-	}()
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Subscribe to block notifications
-	if err = dcrdClient.NotifyBlocks(ctx); err != nil {
-		log.Printf("Failed to start register daemon rpc client for  "+
-			"block notifications: %v\n", err)
-		return 1
-	}
-
 	// Only accept a single CTRL+C
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
@@ -387,82 +371,79 @@ func mainCore() int {
 		cancel()
 	}()
 
-	// Get the current best block (height and hash)
-	hash, err := dcrdClient.GetBestBlockHash(ctx)
+	// Load the agenda metadata which is not provided by dcrd
+	catalog, err := loadAgendaCatalog(cfg.AgendaCatalog)
 	if err != nil {
 		log.Println(err)
 		return 1
 	}
-	// Request the current block header
-	latestBlockHeader, err := dcrdClient.GetBlockHeader(ctx, hash)
+	currentAgendaCatalog.Store(catalog)
+
+	// Open the cache of voting data from previous runs
+	cache, err := newVoteCache(filepath.Join(cfg.DataDir, activeNetParams.Name, voteCacheFilename))
 	if err != nil {
 		log.Println(err)
 		return 1
 	}
 
-	// Run an initial templateInforation update based on current change
-	updatetemplateInformation(ctx, dcrdClient, latestBlockHeader)
-
-	// Run goroutine for notifications
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		for {
-			select {
-			case blkHdr := <-connectChan:
-				log.Printf("Block %v (height %v) connected",
-					blkHdr.BlockHash(), blkHdr.Height)
-				updatetemplateInformation(ctx, dcrdClient, &blkHdr)
-			case <-ctx.Done():
-				log.Println("Closing dcrvotingweb")
-				wg.Done()
-				return
-			}
-		}
-	}()
-
 	// Create new web UI to deal with HTML templates and provide the
 	// http.HandleFunc for the web server
-	webUI, err := NewWebUI()
+	public, err := publicFS(cfg.PublicDir)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	webUI, err := NewWebUI(public)
 	if err != nil {
 		log.Printf("NewWebUI failed: %v", err)
-		os.Exit(1)
+		return 1
 	}
-	webUI.TemplateData = templateInformation
+	webUI.TemplateData = &templateInformation
+	webUI.MaxUpdateAge = cfg.MaxUpdateAge
 	// Register OS signal (USR1 on non-Windows platforms) to reload templates
 	webUI.UseSIGToReloadTemplates()
 
-	noDirListing := func(h http.Handler) http.HandlerFunc {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if strings.HasSuffix(r.URL.Path, "/") {
-				webUI.homePage(w, r)
-				return
-			}
-			h.ServeHTTP(w, r)
-		})
+	// Start the web server.  It shuts down gracefully, completing in-flight
+	// requests, once the context is canceled.
+	server, err := newWebServer(cfg, webUI.router())
+	if err != nil {
+		log.Println(err)
+		return 1
 	}
-
-	// URL handlers for js/css/fonts/images
-	http.HandleFunc("/", webUI.homePage)
-	http.Handle("/js/", noDirListing(http.StripPrefix("/js/", http.FileServer(http.Dir("public/js/")))))
-	http.Handle("/css/", noDirListing(http.StripPrefix("/css/", http.FileServer(http.Dir("public/css/")))))
-	http.Handle("/fonts/", noDirListing(http.StripPrefix("/fonts/", http.FileServer(http.Dir("public/fonts/")))))
-	http.Handle("/images/", noDirListing(http.StripPrefix("/images/", http.FileServer(http.Dir("public/images/")))))
-
-	// Start http server listening and serving, but no way to signal to quit
+	server.onShutdown(webUI.events.close)
+	exitCode := 0
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		log.Printf("Starting webserver on %v", cfg.Listen)
-		err = http.ListenAndServe(cfg.Listen, nil) // #nosec G114 - Ignore linter warning: "G114: Use of net/http serve function that has no support for setting timeouts (gosec)"
-		if err != nil {
-			log.Printf("Failed to bind http server: %v", err)
+		if err := server.run(ctx); err != nil {
+			log.Println(err)
+			exitCode = 1
 			cancel()
 		}
+		wg.Done()
+	}()
+
+	// Connect to dcrd and keep the voting information current.  The web
+	// server reports dcrd as unavailable until this succeeds.
+	u := &updater{
+		cache:     cache,
+		ntfns:     newNotificationQueue(),
+		catalogs:  make(chan *agendaCatalog, 1),
+		onPublish: webUI.events.publish,
+	}
+	// Register OS signal (USR1 on non-Windows platforms) to reload the
+	// agenda catalog
+	UseSIGToReloadAgendaCatalog(cfg.AgendaCatalog, u)
+	wg.Add(1)
+	go func() {
+		newDcrdBackend(cfg, u).run(ctx)
+		wg.Done()
 	}()
 
 	// Wait for goroutines, such as the block connected handler loop
 	wg.Wait()
 
-	return 0
+	return exitCode
 }
 
 // fmtDuration will convert a Duration into a human readable string formatted "0d 0h 0m".
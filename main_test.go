@@ -0,0 +1,611 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	// The updater logs every RPC result, which drowns out test failures.
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// testHarness runs the same wiring as mainCore against a fake dcrd.
+type testHarness struct {
+	t       *testing.T
+	chain   *fakeChain
+	dcrd    *fakeDcrd
+	web     *httptest.Server
+	updates chan int64
+	updater *updater
+}
+
+// newTestHarness configures the test network, starts a fake dcrd serving
+// chain, connects dcrvotingweb to it and waits for the initial update.
+func newTestHarness(t *testing.T, chain *fakeChain) *testHarness {
+	t.Helper()
+	return newTestHarnessWithCache(t, chain, filepath.Join(t.TempDir(), voteCacheFilename))
+}
+
+// newTestHarnessWithCache is newTestHarness with the vote cache persisted at
+// the provided path, which allows a restart of dcrvotingweb to be simulated.
+func newTestHarnessWithCache(t *testing.T, chain *fakeChain, cachePath string) *testHarness {
+	t.Helper()
+	return startTestHarness(t, chain, cachePath, true)
+}
+
+// startTestHarness configures the test network, starts a fake dcrd serving
+// chain and starts dcrvotingweb.  If online is false the fake dcrd initially
+// refuses connections, otherwise the initial update is waited for.
+func startTestHarness(t *testing.T, chain *fakeChain, cachePath string, online bool) *testHarness {
+	t.Helper()
+
+	// Restore the global network state once the test completes.
+	prevParams, prevBlockVersion, prevTemplate := activeNetParams, blockVersion, templateInformation.Load()
+	t.Cleanup(func() {
+		activeNetParams, blockVersion = prevParams, prevBlockVersion
+		templateInformation.Store(prevTemplate)
+	})
+	activeNetParams = chain.params
+	blockVersion = 0
+	templateInformation.Store(newTemplateFields("https://explorer.example"))
+	dcrdTipHeight.Store(0)
+
+	h := &testHarness{
+		t:       t,
+		chain:   chain,
+		dcrd:    newFakeDcrd(t, chain),
+		updates: make(chan int64, 1000),
+	}
+	h.dcrd.setOnline(online)
+
+	cache, err := newVoteCache(cachePath)
+	if err != nil {
+		t.Fatalf("newVoteCache: %v", err)
+	}
+
+	public, err := publicFS("")
+	if err != nil {
+		t.Fatalf("publicFS: %v", err)
+	}
+	webUI, err := NewWebUI(public)
+	if err != nil {
+		t.Fatalf("NewWebUI: %v", err)
+	}
+	webUI.TemplateData = &templateInformation
+	h.web = httptest.NewServer(webUI.router())
+	t.Cleanup(h.web.Close)
+	t.Cleanup(webUI.events.close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := &config{
+		RPCHost:    h.dcrd.host(),
+		RPCUser:    fakeRPCUser,
+		RPCPass:    fakeRPCPass,
+		DisableTLS: true,
+	}
+	u := &updater{
+		cache:     cache,
+		ntfns:     newNotificationQueue(),
+		catalogs:  make(chan *agendaCatalog, 1),
+		onUpdate:  func(height int64) { h.updates <- height },
+		onPublish: webUI.events.publish,
+	}
+	h.updater = u
+	backend := newDcrdBackend(cfg, u)
+	backend.minDelay = 10 * time.Millisecond
+	backend.maxDelay = 50 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		backend.run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	if online {
+		h.waitForHeight(int64(chain.tip().header.Height))
+	}
+	return h
+}
+
+// waitForHeight blocks until the voting information has been updated for the
+// block at the provided height.
+func (h *testHarness) waitForHeight(height int64) {
+	h.t.Helper()
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case updated := <-h.updates:
+			if updated == height {
+				return
+			}
+		case <-timeout:
+			h.t.Fatalf("timeout waiting for update at height %d", height)
+		}
+	}
+}
+
+// mineTo extends the fake chain to the provided height, notifying
+// dcrvotingweb of every new block and waiting for it to process them.
+func (h *testHarness) mineTo(height int64, blockVersion int32, stakeVersion uint32,
+	blockVotes []types.VersionBits) {
+
+	h.t.Helper()
+	h.chain.mineTo(height, blockVersion, stakeVersion, blockVotes)
+	h.dcrd.notifyConnected()
+	h.waitForHeight(height)
+}
+
+// reorg disconnects every block above the provided height from the fake
+// chain, notifying dcrvotingweb and waiting for it to process them.
+func (h *testHarness) reorg(height int64) {
+	h.t.Helper()
+	h.dcrd.reorg(height)
+	h.waitForHeight(height)
+}
+
+// get performs a GET request against the web UI and returns the body.
+func (h *testHarness) get(path string, wantCode int) []byte {
+	h.t.Helper()
+	resp, err := http.Get(h.web.URL + path)
+	if err != nil {
+		h.t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("GET %s: %v", path, err)
+	}
+	if resp.StatusCode != wantCode {
+		h.t.Fatalf("GET %s: status %d, want %d: %s", path, resp.StatusCode,
+			wantCode, body)
+	}
+	return body
+}
+
+// getJSON performs a GET request against the JSON API and decodes the body
+// into v.
+func (h *testHarness) getJSON(path string, v any) {
+	h.t.Helper()
+	if err := json.Unmarshal(h.get(path, http.StatusOK), v); err != nil {
+		h.t.Fatalf("GET %s: %v", path, err)
+	}
+}
+
+// assertPage asserts that the rendered home page contains every provided
+// string.
+func (h *testHarness) assertPage(want ...string) {
+	h.t.Helper()
+	page := string(h.get("/", http.StatusOK))
+	for _, s := range want {
+		if !strings.Contains(page, s) {
+			h.t.Errorf("home page does not contain %q", s)
+		}
+	}
+}
+
+// agenda returns the agenda with the provided ID from the current template
+// data.
+func (h *testHarness) agenda(id string) *Agenda {
+	h.t.Helper()
+	ti := templateInformation.Load()
+	for i := range ti.Agendas {
+		if ti.Agendas[i].ID == id {
+			return &ti.Agendas[i]
+		}
+	}
+	h.t.Fatalf("agenda %q not found", id)
+	return nil
+}
+
+// TestVotingLifecycle walks the test network through a full consensus vote on
+// the maxtreasuryspend agenda and asserts on the computed fields, the rendered
+// home page and the JSON API at each phase.
+//
+// With simnet parameters, stake version intervals start every 112 blocks from
+// height 144, and rule change intervals every 320 blocks from height 144.
+func TestVotingLifecycle(t *testing.T) {
+	const agendaID = "maxtreasuryspend"
+
+	// Upgrade phase.
+	//
+	// Voters start casting v12 votes in the stake version interval 256-367,
+	// and miners start producing v12 blocks at height 260. At height 300 41
+	// of the 100 blocks in the rolling window are v12, and the interval in
+	// which the PoS upgrade threshold is met is not yet complete.
+	chain := upgradingChain()
+	h := newTestHarness(t, chain)
+
+	ti := templateInformation.Load()
+	if ti.BlockHeight != 300 {
+		t.Fatalf("block height %d, want 300", ti.BlockHeight)
+	}
+	if ti.BlockVersionCurrent != 11 || ti.BlockVersionNext != 12 {
+		t.Errorf("block versions current v%d next v%d, want v11 v12",
+			ti.BlockVersionCurrent, ti.BlockVersionNext)
+	}
+	if ti.BlockVersionNextPercentage != 41 || ti.BlockVersionSuccess {
+		t.Errorf("PoW upgrade %.1f%% success %v, want 41%% false",
+			ti.BlockVersionNextPercentage, ti.BlockVersionSuccess)
+	}
+	if !ti.IsUpgrading || ti.PosUpgrade.Completed {
+		t.Errorf("upgrading %v PoS completed %v, want true false",
+			ti.IsUpgrading, ti.PosUpgrade.Completed)
+	}
+	if ti.StakeVersionMostPopular != 12 {
+		t.Errorf("most popular stake version v%d, want v12", ti.StakeVersionMostPopular)
+	}
+	if a := h.agenda(agendaID); !a.IsDefined() || a.StartHeight != 0 {
+		t.Errorf("agenda status %q start height %d, want defined 0",
+			a.Status, a.StartHeight)
+	}
+	h.assertPage("Block #300", "Current phase: Upgrading", "PoW 41.0%",
+		"Change Maximum Treasury Expenditure Policy")
+
+	// Voting phase.
+	//
+	// The PoS upgrade threshold was met in the interval 256-367 so voting
+	// takes place in the next rule change interval, 464-783.  By height 500
+	// every block in the rolling window is v12, and 37 blocks of the voting
+	// window have each included 3 yes, 1 no and 1 abstain vote.
+	ballot := concatVotes(votes(3, 12, bitsYes), votes(1, 12, bitsNo),
+		votes(1, 12, bitsAbstain))
+	h.mineTo(367, 12, 11, votes(5, 12, 0))
+	chain.setStatus(12, agendaID, "started")
+	h.mineTo(500, 12, 12, ballot)
+
+	ti = templateInformation.Load()
+	if !ti.BlockVersionSuccess || !ti.PosUpgrade.Completed || ti.IsUpgrading {
+		t.Errorf("PoW success %v PoS completed %v upgrading %v, want true true false",
+			ti.BlockVersionSuccess, ti.PosUpgrade.Completed, ti.IsUpgrading)
+	}
+	if svi := ti.PosUpgrade.UpgradeInterval; svi.StartHeight != 256 || svi.EndHeight != 368 {
+		t.Errorf("upgrade interval %d-%d, want 256-368", svi.StartHeight, svi.EndHeight)
+	}
+	a := h.agenda(agendaID)
+	if a.StartHeight != 464 || a.EndHeight != 783 {
+		t.Errorf("voting window %d-%d, want 464-783", a.StartHeight, a.EndHeight)
+	}
+	if a.VoteCounts["yes"] != 111 || a.VoteCounts["no"] != 37 || a.VoteCounts["abstain"] != 37 {
+		t.Errorf("vote counts %v, want yes 111 no 37 abstain 37", a.VoteCounts)
+	}
+	if a.QuorumMet() || a.ApprovalRating() != 75 {
+		t.Errorf("quorum met %v approval %.2f, want false 75", a.QuorumMet(), a.ApprovalRating())
+	}
+	h.assertPage("Block #500", "Current phase: Voting", "in-progress",
+		"283 blocks left for voting", "quorum will be met in approximately",
+		"(block 503)", "To pass, <strong>75.00%</strong>")
+
+	var apiAgendaResp apiAgenda
+	h.getJSON("/api/v1/agendas/"+agendaID, &apiAgendaResp)
+	if apiAgendaResp.Status != "started" || apiAgendaResp.TotalVotes != 185 ||
+		apiAgendaResp.ApprovalRating != 75 {
+		t.Errorf("API agenda status %q votes %d approval %.2f, want started 185 75",
+			apiAgendaResp.Status, apiAgendaResp.TotalVotes, apiAgendaResp.ApprovalRating)
+	}
+
+	// The 4 non-abstain votes per block are projected to meet the quorum of
+	// 160 votes 3 blocks later.
+	if p := apiAgendaResp.Projection; p == nil || p.QuorumHeight != 503 ||
+		p.QuorumTime == nil || p.BlocksRemaining != 283 || p.FinalApproval != 75 {
+		t.Errorf("unexpected API projection %+v", p)
+	}
+
+	// Lock-in phase.
+	//
+	// The voting window completes with 960 yes, 320 no and 320 abstain
+	// votes, and the agenda activates one rule change interval later.
+	h.mineTo(783, 12, 12, ballot)
+	chain.setStatus(12, agendaID, "lockedin")
+	h.mineTo(790, 12, 12, ballot)
+
+	ti = templateInformation.Load()
+	a = h.agenda(agendaID)
+	if a.VoteCounts["yes"] != 960 || a.VoteCounts["no"] != 320 || a.VoteCounts["abstain"] != 320 {
+		t.Errorf("vote counts %v, want yes 960 no 320 abstain 320", a.VoteCounts)
+	}
+	if a.BlockLockedIn() != 784 || a.ActivationBlock() != 1104 {
+		t.Errorf("locked in %d activation %d, want 784 1104",
+			a.BlockLockedIn(), a.ActivationBlock())
+	}
+	if a.Projection != nil {
+		t.Errorf("projection %+v after the voting window ended", a.Projection)
+	}
+	if !ti.PendingActivation || ti.RulesActivated {
+		t.Errorf("pending activation %v rules activated %v, want true false",
+			ti.PendingActivation, ti.RulesActivated)
+	}
+	h.assertPage("Current phase: Pending Activation", "Locked In", "1,104")
+
+	var status apiStatus
+	h.getJSON("/api/v1/status", &status)
+	if status.Phase != "pending_activation" || status.BlockHeight != 790 {
+		t.Errorf("API phase %q height %d, want pending_activation 790",
+			status.Phase, status.BlockHeight)
+	}
+
+	// Activation phase.
+	h.mineTo(1103, 12, 12, ballot)
+	chain.setStatus(12, agendaID, "active")
+	h.mineTo(1110, 12, 12, ballot)
+
+	ti = templateInformation.Load()
+	if !ti.RulesActivated || ti.PendingActivation {
+		t.Errorf("rules activated %v pending activation %v, want true false",
+			ti.RulesActivated, ti.PendingActivation)
+	}
+	h.assertPage("Current phase: Rules Activated", "Finished",
+		"The new rules have been activated")
+
+	h.get("/api/v1/agendas/unknown", http.StatusNotFound)
+}
+
+// TestPoSUpgradeBeforePoW ensures that when the PoS upgrade threshold is met
+// before the PoW upgrade is complete, the stake version upgrade is only
+// considered to have happened in the first interval which meets both.
+func TestPoSUpgradeBeforePoW(t *testing.T) {
+	const agendaID = "maxtreasuryspend"
+
+	// Every vote is v12 from the first interval, 144-255, but miners only
+	// start producing v12 blocks at height 331.  At the end of the interval
+	// 256-367 only 37 of the 100 blocks in the rolling window are v12.
+	chain := newFakeChain(testNetParams())
+	chain.mineTo(143, 11, 11, nil)
+	chain.mineTo(330, 11, 11, votes(5, 12, 0))
+	chain.mineTo(367, 12, 11, votes(5, 12, 0))
+	h := newTestHarness(t, chain)
+
+	ti := templateInformation.Load()
+	if ti.PosUpgrade.Completed || !ti.IsUpgrading {
+		t.Errorf("PoS completed %v upgrading %v, want false true",
+			ti.PosUpgrade.Completed, ti.IsUpgrading)
+	}
+	if a := h.agenda(agendaID); a.StartHeight != 0 {
+		t.Errorf("agenda start height %d, want 0", a.StartHeight)
+	}
+
+	// The PoW upgrade is complete by the end of the interval 368-479, so
+	// voting takes place in the rule change interval starting after it.
+	h.mineTo(500, 12, 11, votes(5, 12, 0))
+
+	ti = templateInformation.Load()
+	if svi := ti.PosUpgrade.UpgradeInterval; !ti.PosUpgrade.Completed ||
+		svi.StartHeight != 368 || svi.EndHeight != 480 {
+
+		t.Errorf("PoS completed %v in interval %d-%d, want true 368-480",
+			ti.PosUpgrade.Completed, svi.StartHeight, svi.EndHeight)
+	}
+	if a := h.agenda(agendaID); a.StartHeight != 784 || a.EndHeight != 1103 {
+		t.Errorf("voting window %d-%d, want 784-1103", a.StartHeight, a.EndHeight)
+	}
+}
+
+// TestVoteProgression ensures that the running totals of the votes are
+// recorded at the end of each bucket of blocks in the voting window, and that
+// they are retained when the tally of a finished voting window is cached.
+func TestVoteProgression(t *testing.T) {
+	const agendaID = "maxtreasuryspend"
+
+	// The voting window 464-783 is 320 blocks, so the votes are recorded
+	// every 4 blocks.  At height 500 the 9 complete buckets end at heights
+	// 467-499, and are followed by the tip.
+	ballot := concatVotes(votes(3, 12, bitsYes), votes(1, 12, bitsNo),
+		votes(1, 12, bitsAbstain))
+	chain := upgradingChain()
+	chain.mineTo(367, 12, 11, votes(5, 12, 0))
+	chain.setStatus(12, agendaID, "started")
+	cachePath := filepath.Join(t.TempDir(), voteCacheFilename)
+	h := newTestHarnessWithCache(t, chain, cachePath)
+	h.mineTo(500, 12, 12, ballot)
+
+	p := h.agenda(agendaID).Progression
+	if p.BucketSize != 4 || len(p.Heights) != 10 || p.Heights[0] != 467 ||
+		p.Heights[8] != 499 || p.Heights[9] != 500 {
+		t.Fatalf("bucket size %d heights %v, want 4 [467 471 ... 499 500]",
+			p.BucketSize, p.Heights)
+	}
+	if yes := p.Counts["yes"]; yes[0] != 12 || yes[8] != 108 || yes[9] != 111 {
+		t.Errorf("yes counts %v, want [12 24 ... 108 111]", yes)
+	}
+
+	var progression apiVoteProgression
+	h.getJSON("/api/v1/agendas/"+agendaID+"/progression?version=12", &progression)
+	if progression.BucketSize != 4 || len(progression.Heights) != 10 ||
+		len(progression.Choices) != 3 || progression.Choices[0].ID != "abstain" ||
+		progression.Choices[2].ID != "yes" || progression.Choices[2].Counts[9] != 111 {
+		t.Fatalf("unexpected API progression %+v", progression)
+	}
+	quorum := h.agenda(agendaID).QuorumThreshold
+	for i, approval := range progression.ApprovalPercentages {
+		wantQuorum := 100 * float64(16*(i+1)) / float64(quorum)
+		if i == 9 {
+			wantQuorum = 100 * 148 / float64(quorum)
+		}
+		if approval != 75 || progression.QuorumPercentages[i] != wantQuorum {
+			t.Errorf("point %d approval %.2f quorum %.2f, want 75 %.2f", i,
+				approval, progression.QuorumPercentages[i], wantQuorum)
+		}
+	}
+
+	// The progression of the finished voting window is the same when the
+	// votes are counted and when the cached tally is used.
+	h.mineTo(783, 12, 12, ballot)
+	chain.setStatus(12, agendaID, "lockedin")
+	h.mineTo(900, 12, 12, ballot)
+	counted := h.agenda(agendaID).Progression
+	if len(counted.Heights) != 80 || counted.Heights[79] != 783 ||
+		counted.Counts["no"][79] != 320 {
+		t.Fatalf("%d points ending at height %d with %d no votes, want 80 783 320",
+			len(counted.Heights), counted.Heights[len(counted.Heights)-1],
+			counted.Counts["no"][len(counted.Heights)-1])
+	}
+
+	h = newTestHarnessWithCache(t, chain, cachePath)
+	if cached := h.agenda(agendaID).Progression; !reflect.DeepEqual(cached, counted) {
+		t.Errorf("cached progression %+v, want %+v", cached, counted)
+	}
+
+	h.get("/api/v1/agendas/unknown/progression", http.StatusNotFound)
+	h.get("/api/v1/agendas/"+agendaID+"/progression?version=x", http.StatusBadRequest)
+}
+
+// upgradingChain returns a chain in the upgrade phase at height 300, as
+// described by TestVotingLifecycle.
+func upgradingChain() *fakeChain {
+	chain := newFakeChain(testNetParams())
+	chain.mineTo(143, 11, 11, nil)
+	chain.mineTo(255, 11, 11, votes(5, 11, 0))
+	chain.mineTo(259, 11, 11, votes(5, 12, 0))
+	chain.mineTo(300, 12, 11, votes(5, 12, 0))
+	return chain
+}
+
+// TestFailedUpdateRetainsSnapshot ensures that the previous voting information
+// continues to be served, unmodified, when an update fails part way through.
+func TestFailedUpdateRetainsSnapshot(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	prev := templateInformation.Load()
+	h.dcrd.setFailing("getvoteinfo", true)
+	h.mineTo(310, 12, 11, votes(5, 12, 0))
+
+	if ti := templateInformation.Load(); ti != prev {
+		t.Fatalf("snapshot replaced by failed update (height %d)", ti.BlockHeight)
+	}
+	if prev.BlockHeight != 300 || prev.BlockVersionNextPercentage != 41 {
+		t.Fatalf("snapshot modified by failed update: height %d PoW %.1f%%",
+			prev.BlockHeight, prev.BlockVersionNextPercentage)
+	}
+	h.assertPage("Block #300")
+
+	h.dcrd.setFailing("getvoteinfo", false)
+	h.mineTo(311, 12, 11, votes(5, 12, 0))
+	if height := templateInformation.Load().BlockHeight; height != 311 {
+		t.Fatalf("block height %d after recovery, want 311", height)
+	}
+}
+
+// TestConcurrentRendering serves the home page and JSON API while blocks are
+// being processed.  It relies on the race detector to catch unsynchronized
+// access to the voting information.
+func TestConcurrentRendering(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	done := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		var lastHeight int64
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for _, path := range []string{"/", "/api/v1/agendas"} {
+				resp, err := http.Get(h.web.URL + path)
+				if err != nil {
+					errs <- err
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			resp, err := http.Get(h.web.URL + "/api/v1/status")
+			if err != nil {
+				errs <- err
+				return
+			}
+			var status apiStatus
+			err = json.NewDecoder(resp.Body).Decode(&status)
+			resp.Body.Close()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if status.BlockHeight < lastHeight {
+				errs <- fmt.Errorf("block height went backwards from %d to %d",
+					lastHeight, status.BlockHeight)
+				return
+			}
+			lastHeight = status.BlockHeight
+		}
+	}()
+
+	h.mineTo(400, 12, 11, votes(5, 12, 0))
+	close(done)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpectedBlockVersion(t *testing.T) {
+	prevParams, prevBlockVersion := activeNetParams, blockVersion
+	t.Cleanup(func() {
+		activeNetParams, blockVersion = prevParams, prevBlockVersion
+	})
+	activeNetParams = testNetParams()
+	window := int64(activeNetParams.BlockUpgradeNumToCheck)
+
+	tests := []struct {
+		name       string
+		configured int32
+		counts     map[int32]int64
+		want       int32
+	}{{
+		name:   "newest deployment",
+		counts: map[int32]int64{11: window},
+		want:   12,
+	}, {
+		name:   "upgrade to newest deployment in progress",
+		counts: map[int32]int64{11: window / 2, 12: window / 2},
+		want:   12,
+	}, {
+		name:   "stray blocks of an unknown version",
+		counts: map[int32]int64{12: window - 1, 13: 1},
+		want:   12,
+	}, {
+		name:   "upgrade to an unknown version in progress",
+		counts: map[int32]int64{12: window / 2, 13: window / 4, 14: window / 4},
+		want:   14,
+	}, {
+		name:       "configured",
+		configured: 11,
+		counts:     map[int32]int64{12: window},
+		want:       11,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blockVersion = test.configured
+			if got := expectedBlockVersion(test.counts); got != test.want {
+				t.Fatalf("expected block version %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil/v4"
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/wire"
+)
+
+// agendaStatuses are the possible values of Agenda.Status, exported as a set
+// of gauges of which only the current status is 1.
+var agendaStatuses = []string{"defined", "started", "lockedin", "active", "failed"}
+
+// serviceMetrics records the operational metrics of dcrvotingweb.  The voting
+// metrics are read from templateInformation when they are exported instead.
+var serviceMetrics = newMetrics()
+
+// metrics counts the RPC calls, updates and HTTP requests made since startup.
+type metrics struct {
+	mu sync.Mutex
+
+	rpcCalls  map[string]uint64
+	rpcErrors map[string]uint64
+
+	updates           uint64
+	updateFailures    uint64
+	updateSeconds     float64
+	lastUpdateSeconds float64
+	httpRequests      map[httpRequestLabels]uint64
+}
+
+// httpRequestLabels identifies the HTTP requests counted together.
+type httpRequestLabels struct {
+	route string
+	code  int
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		rpcCalls:     make(map[string]uint64),
+		rpcErrors:    make(map[string]uint64),
+		httpRequests: make(map[httpRequestLabels]uint64),
+	}
+}
+
+// rpcDone records a completed dcrd RPC call.
+func (m *metrics) rpcDone(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rpcCalls[method]++
+	if err != nil {
+		m.rpcErrors[method]++
+	}
+}
+
+// updateDone records a completed update of the voting information.
+func (m *metrics) updateDone(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates++
+	m.updateSeconds += d.Seconds()
+	m.lastUpdateSeconds = d.Seconds()
+	if err != nil {
+		m.updateFailures++
+	}
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to access the underlying
+// ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// countRequests returns a handler which serves requests with mux and counts
+// them by the pattern of the route which served them and their status code.
+func (m *metrics) countRequests(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(rec, r)
+
+		// The mux sets the pattern of the matched route on the
+		// request.  Requests which matched no route are counted
+		// together so that arbitrary paths do not create new series.
+		labels := httpRequestLabels{route: r.Pattern, code: rec.code}
+		if labels.route == "" {
+			labels.route = "none"
+		}
+		if labels.code == 0 {
+			labels.code = http.StatusOK
+		}
+		m.mu.Lock()
+		m.httpRequests[labels]++
+		m.mu.Unlock()
+	})
+}
+
+// instrumentedChain is a chainSource which counts the calls made to, and the
+// errors returned by, another chainSource.
+type instrumentedChain struct {
+	chain   chainSource
+	metrics *metrics
+}
+
+func (c instrumentedChain) GetStakeVersions(ctx context.Context, hash string, count int32) (*types.GetStakeVersionsResult, error) {
+	res, err := c.chain.GetStakeVersions(ctx, hash, count)
+	c.metrics.rpcDone("getstakeversions", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetStakeVersionInfo(ctx context.Context, count int32) (*types.GetStakeVersionInfoResult, error) {
+	res, err := c.chain.GetStakeVersionInfo(ctx, count)
+	c.metrics.rpcDone("getstakeversioninfo", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetVoteInfo(ctx context.Context, version uint32) (*types.GetVoteInfoResult, error) {
+	res, err := c.chain.GetVoteInfo(ctx, version)
+	c.metrics.rpcDone("getvoteinfo", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetBlockHash(ctx context.Context, blockHeight int64) (*chainhash.Hash, error) {
+	res, err := c.chain.GetBlockHash(ctx, blockHeight)
+	c.metrics.rpcDone("getblockhash", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetBestBlockHash(ctx context.Context) (*chainhash.Hash, error) {
+	res, err := c.chain.GetBestBlockHash(ctx)
+	c.metrics.rpcDone("getbestblockhash", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetBlockHeader(ctx context.Context, hash *chainhash.Hash) (*wire.BlockHeader, error) {
+	res, err := c.chain.GetBlockHeader(ctx, hash)
+	c.metrics.rpcDone("getblockheader", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetBlock(ctx context.Context, hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	res, err := c.chain.GetBlock(ctx, hash)
+	c.metrics.rpcDone("getblock", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetRawTransaction(ctx context.Context, txHash *chainhash.Hash) (*dcrutil.Tx, error) {
+	res, err := c.chain.GetRawTransaction(ctx, txHash)
+	c.metrics.rpcDone("getrawtransaction", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetRawMempool(ctx context.Context, txType types.GetRawMempoolTxTypeCmd) ([]*chainhash.Hash, error) {
+	res, err := c.chain.GetRawMempool(ctx, txType)
+	c.metrics.rpcDone("getrawmempool", err)
+	return res, err
+}
+
+func (c instrumentedChain) GetTreasurySpendVotes(ctx context.Context, block *chainhash.Hash, tspends []*chainhash.Hash) (*types.GetTreasurySpendVotesResult, error) {
+	res, err := c.chain.GetTreasurySpendVotes(ctx, block, tspends)
+	c.metrics.rpcDone("gettreasuryspendvotes", err)
+	return res, err
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+// family writes the help and type of a metric family.
+func (mw metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a single sample.  labels are alternating label names and
+// values.
+func (mw metricsWriter) sample(name string, value float64, labels ...string) {
+	mw.w.WriteString(name)
+	if len(labels) > 0 {
+		mw.w.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				mw.w.WriteByte(',')
+			}
+			fmt.Fprintf(mw.w, `%s="%s"`, labels[i], labelValueEscaper.Replace(labels[i+1]))
+		}
+		mw.w.WriteByte('}')
+	}
+	mw.w.WriteByte(' ')
+	mw.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	mw.w.WriteByte('\n')
+}
+
+// gauge writes a metric family with a single unlabeled sample.
+func (mw metricsWriter) gauge(name, help string, value float64) {
+	mw.family(name, "gauge", help)
+	mw.sample(name, value)
+}
+
+// labelValueEscaper escapes label values for the exposition format.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// boolValue returns 1 for true and 0 for false.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// write writes the voting information of t and the operational metrics.
+func (m *metrics) write(w io.Writer, t *templateFields) error {
+	mw := metricsWriter{w: bufio.NewWriter(w)}
+
+	mw.gauge("dcrvotingweb_backend_available",
+		"Whether dcrd is currently connected.", boolValue(t.BackendAvailable))
+	mw.family("dcrvotingweb_reorgs_total", "counter",
+		"Chain reorganizations seen since startup.")
+	mw.sample("dcrvotingweb_reorgs_total", float64(t.Reorgs))
+	mw.family("dcrvotingweb_skipped_updates_total", "counter",
+		"Block notifications coalesced into the update for a later block.")
+	mw.sample("dcrvotingweb_skipped_updates_total", float64(t.SkippedUpdates))
+
+	// The voting information is only exported once it has been computed,
+	// so that missing data is not mistaken for zero values.
+	if t.HasData() {
+		mw.gauge("dcrvotingweb_last_update_timestamp_seconds",
+			"Time of the last successful update of the voting information.",
+			float64(t.LastUpdate.UnixNano())/1e9)
+		mw.gauge("dcrvotingweb_block_height",
+			"Height of the block the voting information was computed for.",
+			float64(t.BlockHeight))
+		mw.gauge("dcrvotingweb_pow_next_version",
+			"Next block version.", float64(t.BlockVersionNext))
+		mw.gauge("dcrvotingweb_pow_next_version_percent",
+			"Share of the next block version in the PoW rolling window.",
+			t.BlockVersionNextPercentage)
+		mw.gauge("dcrvotingweb_stake_version_most_popular",
+			"Most popular stake version other than the current stake version.",
+			float64(t.StakeVersionMostPopular))
+		mw.gauge("dcrvotingweb_stake_version_most_popular_percent",
+			"Share of the possible votes in the current stake version interval "+
+				"cast with the most popular stake version.",
+			t.StakeVersionMostPopularPercentage)
+
+		mw.family("dcrvotingweb_agenda_votes", "gauge",
+			"Votes cast on an agenda in its voting window, by choice.")
+		for i := range t.Agendas {
+			a := &t.Agendas[i]
+			version := strconv.FormatUint(uint64(a.VoteVersion), 10)
+			choices := make([]string, 0, len(a.VoteChoices))
+			for id := range a.VoteChoices {
+				choices = append(choices, id)
+			}
+			slices.Sort(choices)
+			for _, id := range choices {
+				mw.sample("dcrvotingweb_agenda_votes", float64(a.VoteCounts[id]),
+					"agenda", a.ID, "vote_version", version, "choice", id)
+			}
+		}
+		mw.family("dcrvotingweb_agenda_status", "gauge",
+			"Status of an agenda. Only the current status is 1.")
+		for i := range t.Agendas {
+			a := &t.Agendas[i]
+			version := strconv.FormatUint(uint64(a.VoteVersion), 10)
+			for _, status := range agendaStatuses {
+				mw.sample("dcrvotingweb_agenda_status", boolValue(a.Status == status),
+					"agenda", a.ID, "vote_version", version, "status", status)
+			}
+		}
+		mw.family("dcrvotingweb_agenda_quorum_threshold", "gauge",
+			"Non-abstain votes required for an agenda vote to be decided.")
+		for i := range t.Agendas {
+			a := &t.Agendas[i]
+			mw.sample("dcrvotingweb_agenda_quorum_threshold", float64(a.QuorumThreshold),
+				"agenda", a.ID, "vote_version", strconv.FormatUint(uint64(a.VoteVersion), 10))
+		}
+		mw.family("dcrvotingweb_agenda_invalid_votes", "gauge",
+			"Votes of an agenda's vote version in its voting window whose bits match no choice.")
+		for i := range t.Agendas {
+			a := &t.Agendas[i]
+			mw.sample("dcrvotingweb_agenda_invalid_votes", float64(a.InvalidVotes),
+				"agenda", a.ID, "vote_version", strconv.FormatUint(uint64(a.VoteVersion), 10))
+		}
+		mw.family("dcrvotingweb_agenda_other_version_votes", "gauge",
+			"Votes of other vote versions cast in an agenda's voting window.")
+		for i := range t.Agendas {
+			a := &t.Agendas[i]
+			mw.sample("dcrvotingweb_agenda_other_version_votes", float64(a.OtherVersionVotes),
+				"agenda", a.ID, "vote_version", strconv.FormatUint(uint64(a.VoteVersion), 10))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mw.family("dcrvotingweb_update_duration_seconds", "summary",
+		"Time taken to update the voting information.")
+	mw.sample("dcrvotingweb_update_duration_seconds_sum", m.updateSeconds)
+	mw.sample("dcrvotingweb_update_duration_seconds_count", float64(m.updates))
+	mw.gauge("dcrvotingweb_last_update_duration_seconds",
+		"Time taken by the most recent update of the voting information.",
+		m.lastUpdateSeconds)
+	mw.family("dcrvotingweb_update_failures_total", "counter",
+		"Updates of the voting information which failed.")
+	mw.sample("dcrvotingweb_update_failures_total", float64(m.updateFailures))
+
+	methods := make([]string, 0, len(m.rpcCalls))
+	for method := range m.rpcCalls {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+	mw.family("dcrvotingweb_rpc_calls_total", "counter",
+		"dcrd RPC calls, by method.")
+	for _, method := range methods {
+		mw.sample("dcrvotingweb_rpc_calls_total", float64(m.rpcCalls[method]),
+			"method", method)
+	}
+	mw.family("dcrvotingweb_rpc_errors_total", "counter",
+		"dcrd RPC calls which returned an error, by method.")
+	for _, method := range methods {
+		mw.sample("dcrvotingweb_rpc_errors_total", float64(m.rpcErrors[method]),
+			"method", method)
+	}
+
+	requests := make([]httpRequestLabels, 0, len(m.httpRequests))
+	for labels := range m.httpRequests {
+		requests = append(requests, labels)
+	}
+	slices.SortFunc(requests, func(a, b httpRequestLabels) int {
+		if c := strings.Compare(a.route, b.route); c != 0 {
+			return c
+		}
+		return a.code - b.code
+	})
+	mw.family("dcrvotingweb_http_requests_total", "counter",
+		"HTTP requests served, by route pattern and status code.")
+	for _, labels := range requests {
+		mw.sample("dcrvotingweb_http_requests_total", float64(m.httpRequests[labels]),
+			"route", labels.route, "code", strconv.Itoa(labels.code))
+	}
+
+	return mw.w.Flush()
+}
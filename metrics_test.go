@@ -0,0 +1,140 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// metric returns the value of the sample of /metrics with the provided name
+// and labels, e.g. `dcrvotingweb_rpc_calls_total{method="getvoteinfo"}`, or -1
+// if there is no such sample.
+func (h *testHarness) metric(sample string) float64 {
+	h.t.Helper()
+	body := string(h.get("/metrics", http.StatusOK))
+	for _, line := range strings.Split(body, "\n") {
+		value, ok := strings.CutPrefix(line, sample+" ")
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			h.t.Fatalf("sample %q: %v", line, err)
+		}
+		return f
+	}
+	return -1
+}
+
+// TestMetrics ensures that the voting information and the operational metrics
+// are exported, and that RPC errors, failed updates and HTTP requests are
+// counted.
+func TestMetrics(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	resp, err := http.Get(h.web.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type %q", ct)
+	}
+
+	// The metrics are shared by every test, so only their changes are
+	// compared.
+	const (
+		failures  = "dcrvotingweb_update_failures_total"
+		updates   = "dcrvotingweb_update_duration_seconds_count"
+		rpcErrors = `dcrvotingweb_rpc_errors_total{method="getvoteinfo"}`
+		rpcCalls  = `dcrvotingweb_rpc_calls_total{method="getblockhash"}`
+		status    = `dcrvotingweb_http_requests_total{route="GET /api/v1/status",code="200"}`
+		notFound  = `dcrvotingweb_http_requests_total{route="GET /api/v1/agendas/{id}",code="404"}`
+	)
+	prevFailures, prevUpdates := h.metric(failures), h.metric(updates)
+	prevErrors, prevCalls := max(0, h.metric(rpcErrors)), h.metric(rpcCalls)
+	prevStatus, prevNotFound := max(0, h.metric(status)), max(0, h.metric(notFound))
+
+	h.dcrd.setFailing("getvoteinfo", true)
+	h.mineTo(310, 12, 11, votes(5, 12, 0))
+	h.dcrd.setFailing("getvoteinfo", false)
+	h.mineTo(311, 12, 11, votes(5, 12, 0))
+	h.get("/api/v1/status", http.StatusOK)
+	h.get("/api/v1/status", http.StatusOK)
+	h.get("/api/v1/agendas/unknown", http.StatusNotFound)
+
+	if n := h.metric(failures) - prevFailures; n < 1 {
+		t.Errorf("%v more failed updates, want at least 1", n)
+	}
+	if n := h.metric(updates) - prevUpdates; n < 2 {
+		t.Errorf("%v more updates, want at least 2", n)
+	}
+	if n := h.metric(rpcErrors) - prevErrors; n < 1 {
+		t.Errorf("%v more getvoteinfo errors, want at least 1", n)
+	}
+	if n := h.metric(rpcCalls) - prevCalls; n < 2 {
+		t.Errorf("%v more getblockhash calls, want at least 2", n)
+	}
+	if n := h.metric(status) - prevStatus; n != 2 {
+		t.Errorf("%v more status requests, want 2", n)
+	}
+	if n := h.metric(notFound) - prevNotFound; n != 1 {
+		t.Errorf("%v more agenda requests not found, want 1", n)
+	}
+
+	for sample, want := range map[string]float64{
+		"dcrvotingweb_block_height":             311,
+		"dcrvotingweb_backend_available":        1,
+		"dcrvotingweb_pow_next_version":         12,
+		"dcrvotingweb_pow_next_version_percent": 52,
+		`dcrvotingweb_agenda_status{agenda="maxtreasuryspend",vote_version="12",status="defined"}`: 1,
+		`dcrvotingweb_agenda_status{agenda="maxtreasuryspend",vote_version="12",status="started"}`: 0,
+		`dcrvotingweb_agenda_votes{agenda="maxtreasuryspend",vote_version="12",choice="yes"}`:      0,
+	} {
+		if got := h.metric(sample); got != want {
+			t.Errorf("%s = %v, want %v", sample, got, want)
+		}
+	}
+}
+
+// TestMetricsWithoutData ensures that no voting information is exported
+// before it has been computed, and that label values are escaped.
+func TestMetricsWithoutData(t *testing.T) {
+	m := newMetrics()
+	m.rpcDone("get\"block\\hash\n", nil)
+
+	var buf bytes.Buffer
+	if err := m.write(&buf, &templateFields{}); err != nil {
+		t.Fatal(err)
+	}
+	var samples []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		if line := scanner.Text(); !strings.HasPrefix(line, "#") {
+			samples = append(samples, line)
+		}
+	}
+
+	want := []string{
+		"dcrvotingweb_backend_available 0",
+		"dcrvotingweb_reorgs_total 0",
+		"dcrvotingweb_skipped_updates_total 0",
+		"dcrvotingweb_update_duration_seconds_sum 0",
+		"dcrvotingweb_update_duration_seconds_count 0",
+		"dcrvotingweb_last_update_duration_seconds 0",
+		"dcrvotingweb_update_failures_total 0",
+		`dcrvotingweb_rpc_calls_total{method="get\"block\\hash\n"} 1`,
+		`dcrvotingweb_rpc_errors_total{method="get\"block\\hash\n"} 0`,
+	}
+	if strings.Join(samples, "\n") != strings.Join(want, "\n") {
+		t.Errorf("samples\n%s\nwant\n%s", strings.Join(samples, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// dashboardTitle is the title of the home page, and the suffix of the title
+// of every other page.
+const dashboardTitle = "Decred Voting Dashboard"
+
+// PageTitle is the title of the home page.  The other pages, which embed the
+// voting information, override it.
+func (t *templateFields) PageTitle() string {
+	return dashboardTitle
+}
+
+// agenda returns the agenda with the provided ID voted on in the provided vote
+// version, or in the most recent vote version if version is zero, or nil if
+// there is no such agenda.
+func (t *templateFields) agenda(id string, version uint32) *Agenda {
+	var agenda *Agenda
+	for i := range t.Agendas {
+		a := &t.Agendas[i]
+		if a.ID != id || (version != 0 && a.VoteVersion != version) {
+			continue
+		}
+		if agenda == nil || a.VoteVersion > agenda.VoteVersion {
+			agenda = a
+		}
+	}
+	return agenda
+}
+
+// AgendaPath returns the path of the page of the agenda with the provided ID
+// voted on in the provided vote version.  The version is omitted when it is
+// the most recent vote on the agenda, so that the path remains the same for
+// future votes.
+func (t *templateFields) AgendaPath(id string, version uint32) string {
+	path := "/agenda/" + url.PathEscape(id)
+	if latest := t.agenda(id, 0); latest != nil && latest.VoteVersion != version {
+		path += "?version=" + strconv.FormatUint(uint64(version), 10)
+	}
+	return path
+}
+
+// agendaCard is the data of the agenda-card template.
+type agendaCard struct {
+	Page   *templateFields
+	Agenda *Agenda
+}
+
+// Card returns the data to render the card of agenda a with.
+func (t *templateFields) Card(a *Agenda) agendaCard {
+	return agendaCard{Page: t, Agenda: a}
+}
+
+// SortedChoices returns the vote choices ordered by their bits, which by
+// convention puts abstain first.
+func (a *Agenda) SortedChoices() []VoteChoice {
+	choices := make([]VoteChoice, 0, len(a.VoteChoices))
+	for _, c := range a.VoteChoices {
+		choices = append(choices, c)
+	}
+	sort.Slice(choices, func(i, j int) bool {
+		return choices[i].Bits < choices[j].Bits
+	})
+	return choices
+}
+
+// tallyPoint is the running total of the votes on an agenda after a block,
+// for the tally history table.
+type tallyPoint struct {
+	Height int64
+	// Counts are ordered the same as SortedChoices.
+	Counts       []int64
+	Approval     float64
+	Quorum       float64
+	Invalid      int64
+	OtherVersion int64
+}
+
+// TallyHistory returns the points of the vote progression, most recent
+// first.
+func (a *Agenda) TallyHistory() []tallyPoint {
+	choices := a.SortedChoices()
+	approval, quorum := a.ApprovalProgression(), a.QuorumProgression()
+	points := make([]tallyPoint, len(a.Progression.Heights))
+	for i, height := range a.Progression.Heights {
+		counts := make([]int64, len(choices))
+		for j, c := range choices {
+			counts[j] = a.progressionCount(c.ID, i)
+		}
+		points[len(points)-1-i] = tallyPoint{
+			Height:       height,
+			Counts:       counts,
+			Approval:     approval[i],
+			Quorum:       quorum[i],
+			Invalid:      a.Progression.Invalid[i],
+			OtherVersion: a.Progression.OtherVersion[i],
+		}
+	}
+	return points
+}
+
+// agendaPage is the data of the agenda template, which renders the page of a
+// single agenda.
+type agendaPage struct {
+	*templateFields
+	Agenda *Agenda
+	// OtherVersions are the other vote versions the agenda has been voted
+	// on in, most recent first.
+	OtherVersions []uint32
+}
+
+func (p *agendaPage) PageTitle() string {
+	return p.Agenda.Title + " - " + dashboardTitle
+}
+
+// versionPage is the data of the version template, which renders the page of
+// the agendas of a single vote version.
+type versionPage struct {
+	*templateFields
+	VoteVersion uint32
+	Agendas     []Agenda
+}
+
+func (p *versionPage) PageTitle() string {
+	return fmt.Sprintf("Vote Version %d - %s", p.VoteVersion, dashboardTitle)
+}
+
+// tspendTable is the data of the tspend-table template.
+type tspendTable struct {
+	Page    *templateFields
+	TSpends []TSpend
+}
+
+// TSpendTable returns the data to render a table of tspends with.
+func (t *templateFields) TSpendTable(tspends []TSpend) tspendTable {
+	return tspendTable{Page: t, TSpends: tspends}
+}
+
+// InProgressTSpends returns the tspends which have been neither mined nor
+// expired.
+func (t *templateFields) InProgressTSpends() []TSpend {
+	var tspends []TSpend
+	for _, ts := range t.TSpends {
+		if ts.InProgress() {
+			tspends = append(tspends, ts)
+		}
+	}
+	return tspends
+}
+
+// tspendsPage is the data of the tspends template, which renders the page of
+// every tspend.
+type tspendsPage struct {
+	*templateFields
+}
+
+func (p *tspendsPage) PageTitle() string {
+	return "Treasury Spends - " + dashboardTitle
+}
+
+// QuorumPercent returns the percentage of the votes which may be cast in the
+// voting window of a tspend which must be cast for it to be approved.
+func (p *tspendsPage) QuorumPercent() float64 {
+	return 100 * float64(activeNetParams.TreasuryVoteQuorumMultiplier) /
+		float64(activeNetParams.TreasuryVoteQuorumDivisor)
+}
+
+// RequiredApproval returns the percentage of the votes which must be yes for
+// a tspend to be approved.
+func (p *tspendsPage) RequiredApproval() float64 {
+	return tspendRequiredApproval()
+}
+
+// TreasuryVoteInterval returns the number of blocks between those tspends can
+// be mined in.
+func (p *tspendsPage) TreasuryVoteInterval() uint64 {
+	return activeNetParams.TreasuryVoteInterval
+}
+
+// blockPage is the data of the block template, which renders the decoded
+// votes of a single block.
+type blockPage struct {
+	*templateFields
+	Block *BlockVotes
+}
+
+func (p *blockPage) PageTitle() string {
+	return fmt.Sprintf("Block %d Votes - %s", p.Block.Height, dashboardTitle)
+}
+
+// errorPage is the data of the error template.
+type errorPage struct {
+	*templateFields
+	Status  int
+	Message string
+}
+
+func (p *errorPage) PageTitle() string {
+	return p.StatusText() + " - " + dashboardTitle
+}
+
+func (p *errorPage) StatusText() string {
+	return http.StatusText(p.Status)
+}
+
+// setPageHeaders sets the security headers of every HTML page.
+func setPageHeaders(w http.ResponseWriter) {
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-XSS-Protection", "1; mode=block")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+}
+
+// writePage serves the page rendered by the named template from data, which
+// must only depend on the snapshot t.  It is rendered once per snapshot and
+// cached under key.
+func (td *WebUI) writePage(w http.ResponseWriter, r *http.Request,
+	t *templateFields, key, name string, data any) {
+
+	setPageHeaders(w)
+	responses := td.responses.forSnapshot(t, td.TemplateData.Load(), td.templ.Load())
+	resp, err := responses.response(key, func() (*cachedResponse, error) {
+		var buf bytes.Buffer
+		err := responses.templ.ExecuteTemplate(&buf, name, data)
+		if err != nil {
+			return nil, err
+		}
+		return newCachedResponse("text/html; charset=utf-8", responses.modTime, buf.Bytes()), nil
+	})
+	if err != nil {
+		log.Printf("Failed to Execute: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+	// The page must be revalidated, as it changes with every block.
+	resp.serve(w, r, "no-cache")
+}
+
+// renderPage serves the page rendered by the named template from data without
+// caching it, for pages which do not only depend on the voting information.
+func (td *WebUI) renderPage(w http.ResponseWriter, name string, data any) {
+	setPageHeaders(w)
+	var buf bytes.Buffer
+	if err := td.templ.Load().ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("Failed to Execute: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+// writeErrorPage serves a page describing why the request failed with the
+// provided status code.
+func (td *WebUI) writeErrorPage(w http.ResponseWriter, t *templateFields, code int, message string) {
+	setPageHeaders(w)
+	var buf bytes.Buffer
+	page := &errorPage{templateFields: t, Status: code, Message: message}
+	if err := td.templ.Load().ExecuteTemplate(&buf, "error", page); err != nil {
+		log.Printf("Failed to Execute: %v", err)
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
+
+// agendaPage serves the page of the agenda identified by the {id} path
+// value.  If the same agenda ID has been voted on in multiple vote versions,
+// the most recent is served unless the version query parameter is provided.
+func (td *WebUI) agendaPage(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		td.writeErrorPage(w, t, http.StatusServiceUnavailable,
+			"Voting information is not available yet.")
+		return
+	}
+
+	var version uint64
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		version, err = strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			td.writeErrorPage(w, t, http.StatusBadRequest, "Invalid vote version.")
+			return
+		}
+	}
+	agenda := t.agenda(r.PathValue("id"), uint32(version))
+	if agenda == nil {
+		td.writeErrorPage(w, t, http.StatusNotFound, "Agenda not found.")
+		return
+	}
+
+	page := &agendaPage{templateFields: t, Agenda: agenda}
+	for _, a := range t.Agendas {
+		if a.ID == agenda.ID && a.VoteVersion != agenda.VoteVersion {
+			page.OtherVersions = append(page.OtherVersions, a.VoteVersion)
+		}
+	}
+	slices.Sort(page.OtherVersions)
+	slices.Reverse(page.OtherVersions)
+
+	key := fmt.Sprintf("page/agenda/%s/%d", agenda.ID, agenda.VoteVersion)
+	td.writePage(w, r, t, key, "agenda", page)
+}
+
+// versionPage serves the page of the agendas of the vote version identified
+// by the {version} path value.
+func (td *WebUI) versionPage(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		td.writeErrorPage(w, t, http.StatusServiceUnavailable,
+			"Voting information is not available yet.")
+		return
+	}
+
+	version, err := strconv.ParseUint(r.PathValue("version"), 10, 32)
+	if err != nil {
+		td.writeErrorPage(w, t, http.StatusNotFound, "Vote version not found.")
+		return
+	}
+	page := &versionPage{templateFields: t, VoteVersion: uint32(version)}
+	for _, a := range t.Agendas {
+		if a.VoteVersion == page.VoteVersion {
+			page.Agendas = append(page.Agendas, a)
+		}
+	}
+	if len(page.Agendas) == 0 {
+		td.writeErrorPage(w, t, http.StatusNotFound, "Vote version not found.")
+		return
+	}
+
+	td.writePage(w, r, t, fmt.Sprintf("page/version/%d", version), "version", page)
+}
+
+// tspendsPage serves the page of every tspend.
+func (td *WebUI) tspendsPage(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	if !t.HasData() {
+		td.writeErrorPage(w, t, http.StatusServiceUnavailable,
+			"Voting information is not available yet.")
+		return
+	}
+
+	td.writePage(w, r, t, "page/tspends", "tspends", &tspendsPage{templateFields: t})
+}
+
+// findBlockVotes returns the decoded votes of the block identified by the
+// {block} path value, which is a height or a block hash.  When no votes are
+// returned, the status code of the failure is: 404 Not Found for unknown
+// blocks, or 503 Service Unavailable when dcrd could not be queried.
+func (td *WebUI) findBlockVotes(r *http.Request, t *templateFields) (*BlockVotes, int) {
+	chain := dcrdChain.Load()
+	if chain == nil {
+		return nil, http.StatusServiceUnavailable
+	}
+	block, err := td.blocks.blockVotes(r.Context(), chain, t, r.PathValue("block"))
+	switch {
+	case errors.Is(err, errBlockNotFound):
+		return nil, http.StatusNotFound
+	case err != nil:
+		log.Printf("Failed to decode block votes: %v", err)
+		return nil, http.StatusServiceUnavailable
+	}
+	return block, http.StatusOK
+}
+
+// blockPage serves the page of the votes of the block identified by the
+// {block} path value, decoded against the agendas of their vote versions.
+func (td *WebUI) blockPage(w http.ResponseWriter, r *http.Request) {
+	t := td.TemplateData.Load()
+	block, code := td.findBlockVotes(r, t)
+	switch code {
+	case http.StatusNotFound:
+		td.writeErrorPage(w, t, code, "Block not found.")
+		return
+	case http.StatusServiceUnavailable:
+		td.writeErrorPage(w, t, code, "Unable to retrieve the block from dcrd.")
+		return
+	}
+	td.renderPage(w, "block", &blockPage{templateFields: t, Block: block})
+}
+
+// SVIVotes returns the votes cast in each of the stake version intervals
+// charted on the home page, oldest first.
+func (t *templateFields) SVIVotes() []int64 {
+	votes := make([]int64, len(t.SVIParticipation))
+	for i := range t.SVIParticipation {
+		votes[i] = t.SVIParticipation[i].Votes
+	}
+	return votes
+}
+
+// SVIMissedVotes returns the votes missed in each of the stake version
+// intervals charted on the home page, oldest first.
+func (t *templateFields) SVIMissedVotes() []int64 {
+	missed := make([]int64, len(t.SVIParticipation))
+	for i := range t.SVIParticipation {
+		missed[i] = t.SVIParticipation[i].Missed()
+	}
+	return missed
+}
+
+// CurrentSVIVotersStartHeight returns the height of the first block of
+// CurrentSVIVoters, whose last block is the main chain tip.
+func (t *templateFields) CurrentSVIVotersStartHeight() int64 {
+	return t.BlockHeight - int64(len(t.CurrentSVIVoters)) + 1
+}
+
+// CurrentSVIHeights returns the height of each block of CurrentSVIVoters.
+func (t *templateFields) CurrentSVIHeights() []int64 {
+	start := t.CurrentSVIVotersStartHeight()
+	heights := make([]int64, len(t.CurrentSVIVoters))
+	for i := range heights {
+		heights[i] = start + int64(i)
+	}
+	return heights
+}
+
+// TicketsPerBlock returns the number of tickets called to vote in each block.
+func (t *templateFields) TicketsPerBlock() uint16 {
+	return activeNetParams.TicketsPerBlock
+}
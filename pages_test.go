@@ -0,0 +1,76 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// assertContains asserts that the page at path contains every provided string.
+func (h *testHarness) assertContains(path string, page []byte, want ...string) {
+	h.t.Helper()
+	for _, s := range want {
+		if !strings.Contains(string(page), s) {
+			h.t.Errorf("%s does not contain %q", path, s)
+		}
+	}
+}
+
+// TestAgendaPages ensures that every agenda and vote version has a page with
+// its full details, linked from the home page, and that unknown agendas and
+// vote versions are not found.
+func TestAgendaPages(t *testing.T) {
+	h := newTestHarness(t, lockedInChain())
+	a := h.agenda("maxtreasuryspend")
+	explorer := templateInformation.Load().BlockExplorerURL
+
+	h.assertPage(`href="/agenda/maxtreasuryspend"`, `href="/version/12"`)
+
+	const path = "/agenda/maxtreasuryspend"
+	page := h.get(path, http.StatusOK)
+	h.assertContains(path, page,
+		"<title>"+a.Title+" - "+dashboardTitle+"</title>",
+		a.VoteChoices["yes"].Description,
+		"0x0006",
+		fmt.Sprintf(`href="%s/block/%d"`, explorer, a.StartHeight),
+		fmt.Sprintf(`href="%s/block/%d"`, explorer, a.EndHeight),
+		fmt.Sprintf(`href="%s/block/%d"`, explorer, a.BlockLockedIn()),
+		fmt.Sprintf(`href="%s/block/%d"`, explorer, a.ActivationBlock()),
+		"Tally History",
+		// The final tally of the voting window.
+		fmt.Sprintf(`href="%s/block/%d"`, explorer, a.Progression.Heights[len(a.Progression.Heights)-1]),
+		"<td>960</td>", "<td>320</td>",
+		`id="progression-maxtreasuryspend-12"`,
+	)
+	// Only the agenda's card is shown.
+	if strings.Contains(string(page), `id="pow-big"`) {
+		t.Errorf("%s contains the PoW upgrade chart", path)
+	}
+
+	if versioned := h.get(path+"?version=12", http.StatusOK); string(versioned) != string(page) {
+		t.Errorf("%s?version=12 differs from the latest vote", path)
+	}
+
+	page = h.get("/version/12", http.StatusOK)
+	h.assertContains("/version/12", page, "Vote Version 12", a.Title,
+		`href="/agenda/maxtreasuryspend"`)
+
+	for _, test := range []struct {
+		path string
+		code int
+	}{
+		{"/agenda/unknown", http.StatusNotFound},
+		{path + "?version=11", http.StatusNotFound},
+		{path + "?version=latest", http.StatusBadRequest},
+		{"/version/1", http.StatusNotFound},
+		{"/version/latest", http.StatusNotFound},
+	} {
+		page := h.get(test.path, test.code)
+		h.assertContains(test.path, page, http.StatusText(test.code))
+	}
+}
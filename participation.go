@@ -0,0 +1,123 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+)
+
+// Participation is the number of votes cast in a range of blocks, compared
+// with the number which could have been cast.  Every block has a vote slot for
+// each of the tickets called to vote, and slots which are not filled are
+// missed votes.
+type Participation struct {
+	// StartHeight and EndHeight are the first and last blocks, inclusive.
+	StartHeight int64
+	EndHeight   int64
+	Votes       int64
+}
+
+// Blocks returns the number of blocks in the range.
+func (p *Participation) Blocks() int64 {
+	return p.EndHeight - p.StartHeight + 1
+}
+
+// Slots returns the number of votes which could have been cast.  Blocks
+// before the stake validation height have no votes.
+func (p *Participation) Slots() int64 {
+	start := max(p.StartHeight, activeNetParams.StakeValidationHeight)
+	if start > p.EndHeight {
+		return 0
+	}
+	return (p.EndHeight - start + 1) * int64(activeNetParams.TicketsPerBlock)
+}
+
+// Missed returns the number of votes which were not cast.
+func (p *Participation) Missed() int64 {
+	return p.Slots() - p.Votes
+}
+
+// Percentage returns the votes cast as a percentage of the slots.
+func (p *Participation) Percentage() float64 {
+	if p.Slots() <= 0 {
+		return 0
+	}
+	return 100 * float64(p.Votes) / float64(p.Slots())
+}
+
+// intervalParticipation returns the participation in a stake version
+// interval returned by getstakeversioninfo.  The current interval ends at the
+// main chain tip, while the end height of the others is exclusive.
+func intervalParticipation(interval *types.VersionInterval, current bool) Participation {
+	p := Participation{
+		StartHeight: interval.StartHeight,
+		EndHeight:   interval.EndHeight - 1,
+	}
+	if current {
+		p.EndHeight = interval.EndHeight
+	}
+	for _, vc := range interval.VoteVersions {
+		p.Votes += int64(vc.Count)
+	}
+	return p
+}
+
+// currentIntervalVoters returns the number of votes in each block of the
+// current stake version interval, oldest first.  blocks are the stake versions
+// of every block of the interval other than its first, newest first, as
+// requested from getstakeversions, so the votes of the first block are those
+// of the whole interval less those of the other blocks.
+func currentIntervalVoters(blocks []types.StakeVersions, interval *types.VersionInterval) []int64 {
+	first := intervalParticipation(interval, true).Votes
+	for _, b := range blocks {
+		first -= int64(len(b.Votes))
+	}
+	voters := []int64{first}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		voters = append(voters, int64(len(blocks[i].Votes)))
+	}
+	return voters
+}
+
+// Participation returns the participation in the agenda's voting window up to
+// the most recent block counted, or false if voting has not started.
+func (a *Agenda) Participation() (Participation, bool) {
+	heights := a.Progression.Heights
+	if !a.VotingStarted() || len(heights) == 0 {
+		return Participation{}, false
+	}
+	p := Participation{
+		StartHeight: a.StartHeight,
+		EndHeight:   heights[len(heights)-1],
+		Votes:       a.InvalidVotes + a.OtherVersionVotes,
+	}
+	for _, n := range a.VoteCounts {
+		p.Votes += n
+	}
+	return p, true
+}
+
+// votingWindowParticipation returns the participation in each voting window,
+// which is a rule change interval, that agendas have been voted on in, oldest
+// first.  Every vote is counted in the votes of each agenda of the window, so
+// the participation is the same for all of them.
+func votingWindowParticipation(agendas []Agenda) []Participation {
+	var windows []Participation
+	seen := make(map[int64]bool)
+	for i := range agendas {
+		p, ok := agendas[i].Participation()
+		if !ok || seen[p.StartHeight] {
+			continue
+		}
+		seen[p.StartHeight] = true
+		windows = append(windows, p)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].StartHeight < windows[j].StartHeight
+	})
+	return windows
+}
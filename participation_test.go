@@ -0,0 +1,128 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// TestParticipation ensures that the votes cast and missed are reported for
+// each stake version interval, each voting window and each block of the
+// current stake version interval.
+func TestParticipation(t *testing.T) {
+	chain := lockedInChain()
+	h := newTestHarness(t, chain)
+	h.mineTo(905, 12, 12, votes(3, 12, bitsYes))
+
+	var got apiParticipationSummary
+	h.getJSON("/api/v1/participation", &got)
+
+	if got.TicketsPerBlock != 5 {
+		t.Errorf("tickets per block: got %d, want 5", got.TicketsPerBlock)
+	}
+	wantSVIs := []apiParticipation{{
+		StartHeight: 592, EndHeight: 703, Blocks: 112, Slots: 560,
+		Votes: 560, Percentage: 100,
+	}, {
+		StartHeight: 704, EndHeight: 815, Blocks: 112, Slots: 560,
+		Votes: 560, Percentage: 100,
+	}, {
+		StartHeight: 816, EndHeight: 905, Blocks: 90, Slots: 450,
+		Votes: 440, MissedVotes: 10, Percentage: 100 * 440.0 / 450,
+	}}
+	if n := len(got.StakeVersionIntervals); n < len(wantSVIs) {
+		t.Fatalf("got %d stake version intervals, want at least %d", n, len(wantSVIs))
+	}
+	gotSVIs := got.StakeVersionIntervals[len(got.StakeVersionIntervals)-len(wantSVIs):]
+	if !reflect.DeepEqual(gotSVIs, wantSVIs) {
+		t.Errorf("stake version intervals:\ngot  %+v\nwant %+v", gotSVIs, wantSVIs)
+	}
+
+	// The agenda was voted on, with every vote cast, in the rule change
+	// interval before the current one.
+	wantWindows := []apiParticipation{{
+		StartHeight: 464, EndHeight: 783, Blocks: 320, Slots: 1600,
+		Votes: 1600, Percentage: 100,
+	}}
+	if !reflect.DeepEqual(got.VotingWindows, wantWindows) {
+		t.Errorf("voting windows:\ngot  %+v\nwant %+v", got.VotingWindows, wantWindows)
+	}
+
+	if got.CurrentIntervalStartHeight != 816 {
+		t.Errorf("current interval start: got %d, want 816",
+			got.CurrentIntervalStartHeight)
+	}
+	wantVoters := make([]int64, 90)
+	for i := range wantVoters {
+		wantVoters[i] = 5
+		if i >= 85 {
+			wantVoters[i] = 3
+		}
+	}
+	if !reflect.DeepEqual(got.CurrentIntervalVoters, wantVoters) {
+		t.Errorf("current interval voters:\ngot  %v\nwant %v",
+			got.CurrentIntervalVoters, wantVoters)
+	}
+
+	page := h.get("/", http.StatusOK)
+	h.assertContains("/", page, "Vote Participation", `id="participation-svi"`,
+		`id="participation-blocks"`, "RCI 464 &ndash; 783", "97.77%")
+}
+
+// TestParticipationBeforeStakeValidation ensures that blocks before the stake
+// validation height, which have no votes, are not counted as missed votes.
+func TestParticipationBeforeStakeValidation(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	var got apiParticipationSummary
+	h.getJSON("/api/v1/participation", &got)
+	first := got.StakeVersionIntervals[0]
+	if first.StartHeight >= activeNetParams.StakeValidationHeight {
+		t.Fatalf("first interval starts at %d, after the stake validation height",
+			first.StartHeight)
+	}
+	for _, p := range got.StakeVersionIntervals {
+		if p.MissedVotes != 0 {
+			t.Errorf("interval %d-%d: got %d missed votes, want 0",
+				p.StartHeight, p.EndHeight, p.MissedVotes)
+		}
+	}
+}
+
+// TestCurrentIntervalVoters ensures that the voters of the first block of the
+// current stake version interval, which getstakeversions is not asked for, are
+// derived from the interval's votes without changing the missed votes counted
+// towards the stake version upgrade.
+func TestCurrentIntervalVoters(t *testing.T) {
+	chain := newFakeChain(testNetParams())
+	chain.mineTo(143, 11, 11, nil)
+	chain.mineTo(255, 11, 11, votes(5, 12, 0))
+	chain.mineTo(256, 11, 11, votes(3, 12, 0))
+	chain.mineTo(260, 11, 11, votes(5, 12, 0))
+	h := newTestHarness(t, chain)
+
+	var got apiParticipationSummary
+	h.getJSON("/api/v1/participation", &got)
+	if want := []int64{3, 5, 5, 5, 5}; got.CurrentIntervalStartHeight != 256 ||
+		!reflect.DeepEqual(got.CurrentIntervalVoters, want) {
+		t.Errorf("current interval voters: got %v from %d, want %v from 256",
+			got.CurrentIntervalVoters, got.CurrentIntervalStartHeight, want)
+	}
+	last := got.StakeVersionIntervals[len(got.StakeVersionIntervals)-1]
+	if last.StartHeight != 256 || last.EndHeight != 260 || last.MissedVotes != 2 {
+		t.Errorf("current interval: got %+v, want 256-260 with 2 missed votes", last)
+	}
+
+	// The upgrade percentage only counts the votes missed after the first
+	// block of the interval.
+	var svis apiStakeVersionIntervals
+	h.getJSON("/api/v1/stakeversionintervals", &svis)
+	if want := 100 * 23.0 / 560; svis.MostPopularShare != want {
+		t.Errorf("most popular percentage: got %v, want %v",
+			svis.MostPopularShare, want)
+	}
+}
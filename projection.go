@@ -0,0 +1,144 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// projectionRecentPoints is the number of most recent points of the vote
+// progression over which the voting rates are measured.  Only recent votes are
+// extrapolated so that the projection follows changes in voter sentiment.
+const projectionRecentPoints = 10
+
+// VoteProjection extrapolates the votes recently cast on an agenda to the end
+// of its voting window.
+type VoteProjection struct {
+	// Height is the most recent block counted.
+	Height int64
+	// BlocksRemaining is the number of blocks left in the voting window.
+	BlocksRemaining int64
+	// YesPerBlock and NoPerBlock are the recent average number of yes and
+	// no votes per block.
+	YesPerBlock float64
+	NoPerBlock  float64
+	// QuorumHeight is the height at which the quorum was met, or is
+	// projected to be met.  It is -1 if the quorum is not projected to be
+	// met before the end of the voting window.
+	QuorumHeight int64
+	// QuorumTime is the estimated time of QuorumHeight.  It is the zero
+	// time if the quorum has already been met or is not projected to be.
+	QuorumTime time.Time
+	// FinalApproval is the projected approval rating, as a percentage, at
+	// the end of the voting window.
+	FinalApproval float64
+	// MinYesShareToPass and MinNoShareToFail are the minimum percentages of
+	// the projected remaining non-abstain votes which must be yes for the
+	// agenda to pass, and no for it to fail.  Zero means the votes cast so
+	// far are enough, while values over 100 mean the result is not
+	// possible at the projected participation.
+	MinYesShareToPass float64
+	MinNoShareToFail  float64
+}
+
+// QuorumProjected indicates if the quorum is projected to be met before the
+// end of the voting window.
+func (p *VoteProjection) QuorumProjected() bool {
+	return p.QuorumHeight != -1
+}
+
+// CanPass indicates if enough votes remain for the agenda to pass.
+func (p *VoteProjection) CanPass() bool {
+	return p.MinYesShareToPass <= 100
+}
+
+// CanFail indicates if enough votes remain for the agenda to fail.
+func (p *VoteProjection) CanFail() bool {
+	return p.MinNoShareToFail <= 100
+}
+
+// project returns the projection of the votes counted so far in the voting
+// window to its end.  It must only be called while the voting window is in
+// progress.
+func (a *Agenda) project(now time.Time) *VoteProjection {
+	p := &a.Progression
+	last := len(p.Heights) - 1
+	if last < 0 {
+		return nil
+	}
+	height := p.Heights[last]
+
+	// Measure the voting rates over the most recent points, or from the
+	// start of the voting window if there is only a single point.
+	earlier := max(0, last-projectionRecentPoints)
+	blocks := height - p.Heights[earlier]
+	yes := a.progressionCount("yes", last) - a.progressionCount("yes", earlier)
+	no := a.progressionCount("no", last) - a.progressionCount("no", earlier)
+	if earlier == last {
+		blocks = height - a.StartHeight + 1
+		yes, no = a.progressionCount("yes", last), a.progressionCount("no", last)
+	}
+
+	proj := &VoteProjection{
+		Height:          height,
+		BlocksRemaining: a.EndHeight - height,
+		YesPerBlock:     float64(yes) / float64(blocks),
+		NoPerBlock:      float64(no) / float64(blocks),
+		QuorumHeight:    -1,
+	}
+
+	nonAbstain := a.TotalNonAbstainVotes()
+	nonAbstainPerBlock := proj.YesPerBlock + proj.NoPerBlock
+	switch {
+	case nonAbstain >= a.QuorumThreshold:
+		// The quorum was met in the first bucket which reached it.
+		for i := range p.Heights {
+			if a.progressionCount("yes", i)+a.progressionCount("no", i) >= a.QuorumThreshold {
+				proj.QuorumHeight = p.Heights[i]
+				break
+			}
+		}
+	case nonAbstainPerBlock > 0:
+		blocksToQuorum := int64(math.Ceil(float64(a.QuorumThreshold-nonAbstain) / nonAbstainPerBlock))
+		if blocksToQuorum <= proj.BlocksRemaining {
+			proj.QuorumHeight = height + blocksToQuorum
+			proj.QuorumTime = now.Add(time.Duration(blocksToQuorum) *
+				activeNetParams.TargetTimePerBlock)
+		}
+	}
+
+	finalYes := float64(a.VoteCounts["yes"]) + proj.YesPerBlock*float64(proj.BlocksRemaining)
+	finalNo := float64(a.VoteCounts["no"]) + proj.NoPerBlock*float64(proj.BlocksRemaining)
+	if finalYes+finalNo > 0 {
+		proj.FinalApproval = 100 * finalYes / (finalYes + finalNo)
+	}
+
+	// The share of the remaining votes needed is relative to the projected
+	// number of remaining non-abstain votes.  When no recent votes were
+	// cast, every remaining ticket is assumed to vote instead.
+	remaining := nonAbstainPerBlock * float64(proj.BlocksRemaining)
+	if remaining < 1 {
+		remaining = float64(proj.BlocksRemaining) * float64(activeNetParams.TicketsPerBlock)
+	}
+	proj.MinYesShareToPass = minShareToReach(a.VoteCounts["yes"], nonAbstain, remaining)
+	proj.MinNoShareToFail = minShareToReach(a.VoteCounts["no"], nonAbstain, remaining)
+
+	return proj
+}
+
+// minShareToReach returns the minimum percentage of the remaining non-abstain
+// votes which must be cast for a choice with the given number of votes so that
+// the choice reaches the rule change activation threshold.  remaining must be
+// positive.
+func minShareToReach(votes, nonAbstain int64, remaining float64) float64 {
+	threshold := float64(activeNetParams.RuleChangeActivationMultiplier) /
+		float64(activeNetParams.RuleChangeActivationDivisor)
+	needed := threshold*(float64(nonAbstain)+remaining) - float64(votes)
+	if needed <= 0 {
+		return 0
+	}
+	return 100 * needed / remaining
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProject(t *testing.T) {
+	prevParams := activeNetParams
+	t.Cleanup(func() { activeNetParams = prevParams })
+	activeNetParams = testNetParams()
+
+	// Each test agenda votes in the window 464-783 with a quorum of 160
+	// votes.  The progression records the cumulative votes every 4 blocks.
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		heights []int64
+		yes     []int64
+		no      []int64
+		want    VoteProjection
+	}{{
+		// The rates are measured from the start of the voting window.
+		name:    "single point",
+		heights: []int64{467},
+		yes:     []int64{12},
+		no:      []int64{4},
+		want: VoteProjection{
+			Height:            467,
+			BlocksRemaining:   316,
+			YesPerBlock:       3,
+			NoPerBlock:        1,
+			QuorumHeight:      503,
+			QuorumTime:        now.Add(36 * time.Second),
+			FinalApproval:     75,
+			MinYesShareToPass: 100 * (0.75*1280 - 12) / 1264,
+			MinNoShareToFail:  100 * (0.75*1280 - 4) / 1264,
+		},
+	}, {
+		// Only the last 10 points are used to measure the rates, during
+		// which every vote was no.  The quorum was met at the end of the
+		// 8th bucket.
+		name:    "changed sentiment",
+		heights: []int64{467, 471, 475, 479, 483, 487, 491, 495, 499, 503, 507, 511},
+		yes:     []int64{20, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40, 40},
+		no:      []int64{0, 0, 20, 40, 60, 80, 100, 120, 140, 160, 180, 200},
+		want: VoteProjection{
+			Height:            511,
+			BlocksRemaining:   272,
+			YesPerBlock:       0,
+			NoPerBlock:        5,
+			QuorumHeight:      495,
+			FinalApproval:     100 * 40.0 / 1600,
+			MinYesShareToPass: 100 * (0.75*1600 - 40) / 1360,
+			MinNoShareToFail:  100 * (0.75*1600 - 200) / 1360,
+		},
+	}, {
+		// Too few blocks remain for the yes votes to catch up.
+		name:    "decided",
+		heights: []int64{767, 771},
+		yes:     []int64{0, 0},
+		no:      []int64{380, 400},
+		want: VoteProjection{
+			Height:            771,
+			BlocksRemaining:   12,
+			YesPerBlock:       0,
+			NoPerBlock:        5,
+			QuorumHeight:      767,
+			FinalApproval:     0,
+			MinYesShareToPass: 100 * (0.75 * 460) / 60,
+			MinNoShareToFail:  0,
+		},
+	}, {
+		// Without any recent non-abstain votes the quorum is not
+		// projected to be met, and every remaining ticket is assumed to
+		// vote.
+		name:    "no votes",
+		heights: []int64{467, 471},
+		yes:     []int64{0, 0},
+		no:      []int64{0, 0},
+		want: VoteProjection{
+			Height:            471,
+			BlocksRemaining:   312,
+			QuorumHeight:      -1,
+			MinYesShareToPass: 75,
+			MinNoShareToFail:  75,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			last := len(test.heights) - 1
+			a := Agenda{
+				QuorumThreshold: 160,
+				StartHeight:     464,
+				EndHeight:       783,
+				VoteCounts:      map[string]int64{"yes": test.yes[last], "no": test.no[last]},
+				Progression: VoteProgression{
+					BucketSize: 4,
+					Heights:    test.heights,
+					Counts:     map[string][]int64{"yes": test.yes, "no": test.no},
+				},
+			}
+			got := a.project(now)
+
+			floatsEqual := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+			if got.Height != test.want.Height || got.BlocksRemaining != test.want.BlocksRemaining ||
+				got.QuorumHeight != test.want.QuorumHeight ||
+				!got.QuorumTime.Equal(test.want.QuorumTime) ||
+				!floatsEqual(got.YesPerBlock, test.want.YesPerBlock) ||
+				!floatsEqual(got.NoPerBlock, test.want.NoPerBlock) ||
+				!floatsEqual(got.FinalApproval, test.want.FinalApproval) ||
+				!floatsEqual(got.MinYesShareToPass, test.want.MinYesShareToPass) ||
+				!floatsEqual(got.MinNoShareToFail, test.want.MinNoShareToFail) {
+				t.Errorf("projection %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// live.js keeps the dashboard current while it is open.  The server sends an
+// update event on /api/v1/events whenever the voting information changes, and
+// the page is then fetched and its contents replaced in place, redrawing the
+// charts, rather than reloading it.  Browsers without server-sent events fall
+// back to reloading the page every five minutes.
+(function () {
+  'use strict';
+
+  var fallbackReloadInterval = 5 * 60 * 1000;
+
+  // timeSince formats the time since date the same as the server does.
+  function timeSince(date) {
+    var seconds = Math.floor((Date.now() - date) / 1000);
+    var units = [[86400, 'day'], [3600, 'hour'], [60, 'minute'], [1, 'second']];
+    for (var i = 0; i < units.length; i++) {
+      var n = Math.floor(seconds / units[i][0]);
+      if (n >= 1) {
+        return n + ' ' + units[i][1] + (n > 1 ? 's' : '') + ' ago';
+      }
+    }
+    return 'now';
+  }
+
+  // The page is only rendered once per update, so keep the time since the
+  // update current.
+  function updateTimeSince() {
+    var elements = document.getElementsByClassName('time-since');
+    for (var i = 0; i < elements.length; i++) {
+      elements[i].textContent = timeSince(new Date(elements[i].getAttribute('datetime')));
+    }
+  }
+  setInterval(updateTimeSince, 10000);
+
+  if (!window.EventSource || !window.fetch || !window.DOMParser) {
+    setTimeout(function () { location.reload(); }, fallbackReloadInterval);
+    return;
+  }
+
+  // replaceElement replaces the element with the ID or selector in the page
+  // with the one from doc.
+  function replaceElement(selector, doc) {
+    var current = document.querySelector(selector);
+    var next = doc.querySelector(selector);
+    if (current && next) {
+      current.parentNode.replaceChild(document.importNode(next, true), current);
+    }
+  }
+
+  // update replaces the dashboard with the one in the fetched page doc.
+  function update(doc) {
+    // Remember which charts were toggled open, so they stay open.
+    var toggled = [];
+    var togglers = document.getElementsByClassName('chart-toggle-side');
+    for (var i = 0; i < togglers.length; i++) {
+      toggled.push(togglers[i].classList.contains('active'));
+    }
+
+    // Charts are drawn on the canvases being replaced.
+    if (window.Chart && Chart.instances) {
+      Object.keys(Chart.instances).forEach(function (id) {
+        Chart.instances[id].destroy();
+      });
+    }
+
+    replaceElement('#vote-bars', doc);
+    replaceElement('.main', doc);
+
+    // Scripts inserted by replacing elements are not run, so the chart
+    // scripts are recreated.
+    var charts = document.getElementById('chart-js');
+    var nextCharts = doc.getElementById('chart-js');
+    if (charts && nextCharts) {
+      charts.innerHTML = '';
+      var scripts = nextCharts.getElementsByTagName('script');
+      for (var j = 0; j < scripts.length; j++) {
+        var script = document.createElement('script');
+        script.text = scripts[j].text;
+        charts.appendChild(script);
+      }
+    }
+
+    togglers = document.getElementsByClassName('chart-toggle-side');
+    for (var k = 0; k < togglers.length && k < toggled.length; k++) {
+      if (toggled[k]) {
+        togglers[k].click();
+      }
+    }
+
+    document.body.setAttribute('data-update-id', doc.body.getAttribute('data-update-id'));
+    updateTimeSince();
+  }
+
+  // refresh fetches the page and updates the dashboard with it.  Events
+  // received while a refresh is in progress result in a single further
+  // refresh once it completes.
+  var refreshing = false;
+  var pending = false;
+  function refresh() {
+    if (refreshing) {
+      pending = true;
+      return;
+    }
+    refreshing = true;
+    fetch(location.pathname + location.search, { credentials: 'same-origin' }).then(function (resp) {
+      if (!resp.ok) {
+        throw new Error('status ' + resp.status);
+      }
+      return resp.text();
+    }).then(function (html) {
+      update(new DOMParser().parseFromString(html, 'text/html'));
+    }).catch(function (err) {
+      console.log('Failed to refresh the dashboard: ' + err);
+    }).then(function () {
+      refreshing = false;
+      if (pending) {
+        pending = false;
+        refresh();
+      }
+    });
+  }
+
+  var events = new EventSource('/api/v1/events');
+  events.addEventListener('update', function (e) {
+    var event = JSON.parse(e.data);
+    if (event.update_id !== document.body.getAttribute('data-update-id')) {
+      refresh();
+    }
+  });
+  // The browser reconnects after network errors, but gives up if the
+  // server refuses the stream.
+  events.addEventListener('error', function () {
+    if (events.readyState === EventSource.CLOSED) {
+      setInterval(refresh, fallbackReloadInterval);
+    }
+  });
+})();
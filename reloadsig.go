@@ -1,4 +1,4 @@
-// Copyright (c) 2017-2022 The Decred developers
+// Copyright (c) 2017-2025 The Decred developers
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
@@ -13,3 +13,9 @@ import "syscall"
 func (td *WebUI) UseSIGToReloadTemplates() {
 	td.reloadTemplatesSig(syscall.SIGUSR1)
 }
+
+// UseSIGToReloadAgendaCatalog reloads the agenda catalog file at path on the
+// same signal as UseSIGToReloadTemplates.
+func UseSIGToReloadAgendaCatalog(path string, u *updater) {
+	reloadAgendaCatalogSig(syscall.SIGUSR1, path, u)
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2017-2022 The Decred developers
+// Copyright (c) 2017-2025 The Decred developers
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
@@ -13,3 +13,7 @@ import "fmt"
 func (td *WebUI) UseSIGToReloadTemplates() {
 	fmt.Println("Signals are unsupported on Windows.")
 }
+
+// UseSIGToReloadAgendaCatalog does nothing on Windows systems, where there are
+// no signals to use.
+func UseSIGToReloadAgendaCatalog(path string, u *updater) {}
@@ -0,0 +1,234 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressMinSize is the size below which responses are not compressed, as
+// the compression overhead outweighs any saving.
+const compressMinSize = 512
+
+// brotliLevel is the brotli compression level of responses.  The highest
+// levels are many times slower for a few percent smaller responses, which
+// would delay the first request for each response after every update.
+const brotliLevel = 6
+
+// cachedResponse is a response body which is rendered once and served to
+// every request for it, along with its gzip and brotli compressed forms.
+type cachedResponse struct {
+	contentType string
+	modTime     time.Time
+	// hash identifies the content of body.
+	hash string
+	body []byte
+	// gzipped and brotli are nil when the body is not worth compressing.
+	gzipped []byte
+	brotli  []byte
+}
+
+func newCachedResponse(contentType string, modTime time.Time, body []byte) *cachedResponse {
+	sum := sha256.Sum256(body)
+	resp := &cachedResponse{
+		contentType: contentType,
+		modTime:     modTime,
+		hash:        hex.EncodeToString(sum[:16]),
+		body:        body,
+	}
+	if len(body) < compressMinSize || !compressible(contentType) {
+		return resp
+	}
+
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(body)
+	zw.Close()
+	if buf.Len() < len(body) {
+		resp.gzipped = buf.Bytes()
+	}
+
+	var brBuf bytes.Buffer
+	bw := brotli.NewWriterLevel(&brBuf, brotliLevel)
+	bw.Write(body)
+	bw.Close()
+	if brBuf.Len() < len(body) {
+		resp.brotli = brBuf.Bytes()
+	}
+	return resp
+}
+
+// compressible returns whether content of the MIME type is text, which
+// compresses well, rather than an already compressed format such as an image
+// or font.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch mediaType {
+	case "application/json", "application/javascript", "image/svg+xml",
+		"application/manifest+json", "application/xml":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// serve writes the response with the provided Cache-Control header, brotli or
+// gzip compressed if the client accepts it.  Conditional, range and HEAD
+// requests are handled by http.ServeContent.
+func (c *cachedResponse) serve(w http.ResponseWriter, r *http.Request, cacheControl string) {
+	h := w.Header()
+	h.Set("Content-Type", c.contentType)
+	h.Set("Cache-Control", cacheControl)
+	body, etag := c.body, `"`+c.hash+`"`
+	if c.gzipped != nil || c.brotli != nil {
+		h.Add("Vary", "Accept-Encoding")
+		var gzipQ, brQ float64
+		if c.gzipped != nil {
+			gzipQ = acceptedQuality(r, "gzip")
+		}
+		if c.brotli != nil {
+			brQ = acceptedQuality(r, "br")
+		}
+		// A strong ETag must differ for each encoding of the body.
+		// Brotli is preferred when both are equally acceptable, as it
+		// compresses text better.
+		switch {
+		case brQ > 0 && brQ >= gzipQ:
+			body, etag = c.brotli, `"`+c.hash+`-br"`
+			h.Set("Content-Encoding", "br")
+		case gzipQ > 0:
+			body, etag = c.gzipped, `"`+c.hash+`-gzip"`
+			h.Set("Content-Encoding", "gzip")
+		}
+	}
+	h.Set("ETag", etag)
+	http.ServeContent(w, r, "", c.modTime, bytes.NewReader(body))
+}
+
+// acceptedQuality returns the quality value the request's Accept-Encoding
+// header gives the provided content coding, or zero if the coding is not
+// acceptable.  An explicit coding takes precedence over the * wildcard.
+func acceptedQuality(r *http.Request, coding string) float64 {
+	codingQ, anyQ := -1.0, -1.0
+	for _, field := range r.Header.Values("Accept-Encoding") {
+		for _, accepted := range strings.Split(field, ",") {
+			name, params, _ := strings.Cut(accepted, ";")
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(k, "q") {
+					var err error
+					if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 {
+						q = 0
+					}
+				}
+			}
+			switch name = strings.TrimSpace(name); {
+			case strings.EqualFold(name, coding):
+				codingQ = q
+			case name == "*":
+				anyQ = q
+			}
+		}
+	}
+	if codingQ >= 0 {
+		return codingQ
+	}
+	return max(anyQ, 0)
+}
+
+// snapshotResponses holds the responses rendered from a single snapshot of
+// the voting information with a single set of templates.
+type snapshotResponses struct {
+	snapshot *templateFields
+	templ    *template.Template
+	// modTime is the Last-Modified time of every response.
+	modTime time.Time
+
+	mu        sync.Mutex
+	responses map[string]*lazyResponse
+}
+
+// lazyResponse is rendered by the first request for it.  Concurrent requests
+// wait for it rather than rendering it again.
+type lazyResponse struct {
+	once sync.Once
+	resp *cachedResponse
+	err  error
+}
+
+// responseCache caches the responses rendered from the most recent snapshot
+// of the voting information, so that the templates are executed and the JSON
+// encoded once per snapshot rather than once per request.
+type responseCache struct {
+	current atomic.Pointer[snapshotResponses]
+}
+
+// forSnapshot returns the responses rendered from snapshot t with templ.
+// Only the responses of the latest snapshot, which the caller provides, are
+// kept.  An older snapshot, loaded by a request before it was replaced, is
+// rendered without being cached and without a Last-Modified time, as it must
+// not be considered newer than the latest snapshot.
+func (c *responseCache) forSnapshot(t, latest *templateFields, templ *template.Template) *snapshotResponses {
+	for {
+		prev := c.current.Load()
+		if prev != nil && prev.snapshot == t && prev.templ == templ {
+			return prev
+		}
+		if t != latest {
+			return &snapshotResponses{
+				snapshot:  t,
+				templ:     templ,
+				responses: make(map[string]*lazyResponse),
+			}
+		}
+
+		// Last-Modified has a resolution of one second, so each snapshot
+		// is given a later time than the previous one to ensure a client
+		// which revalidates with If-Modified-Since is sent a snapshot
+		// replaced within the same second.
+		modTime := time.Now().Truncate(time.Second)
+		if prev != nil && !modTime.After(prev.modTime) {
+			modTime = prev.modTime.Add(time.Second)
+		}
+		next := &snapshotResponses{
+			snapshot:  t,
+			templ:     templ,
+			modTime:   modTime,
+			responses: make(map[string]*lazyResponse),
+		}
+		if c.current.CompareAndSwap(prev, next) {
+			return next
+		}
+	}
+}
+
+// response returns the response identified by key, calling render to render
+// it if it has not already been.
+func (s *snapshotResponses) response(key string, render func() (*cachedResponse, error)) (*cachedResponse, error) {
+	s.mu.Lock()
+	lr, ok := s.responses[key]
+	if !ok {
+		lr = new(lazyResponse)
+		s.responses[key] = lr
+	}
+	s.mu.Unlock()
+
+	lr.once.Do(func() {
+		lr.resp, lr.err = render()
+	})
+	return lr.resp, lr.err
+}
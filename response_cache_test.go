@@ -0,0 +1,215 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// TestAcceptedQuality ensures that content codings are negotiated according
+// to the Accept-Encoding header.
+func TestAcceptedQuality(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		coding         string
+		want           float64
+	}{
+		{"", "gzip", 0},
+		{"gzip", "gzip", 1},
+		{"GZIP", "gzip", 1},
+		{"deflate, gzip;q=0.5, br", "gzip", 0.5},
+		{"deflate, gzip;q=0.5, br", "br", 1},
+		{"gzip;q=0", "gzip", 0},
+		{"gzip; q=0.000", "gzip", 0},
+		{"br, identity", "gzip", 0},
+		{"*", "gzip", 1},
+		{"*;q=0", "br", 0},
+		{"gzip;q=0, *", "gzip", 0},
+		{"gzip;q=0, *", "br", 1},
+		{"*;q=0, gzip", "gzip", 1},
+		{"gzip;q=invalid", "gzip", 0},
+		{"br;q=-1, *", "br", 0},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		if got := acceptedQuality(r, test.coding); got != test.want {
+			t.Errorf("Accept-Encoding %q: %s quality %v, want %v",
+				test.acceptEncoding, test.coding, got, test.want)
+		}
+	}
+}
+
+// request performs a GET request against the web UI with the provided
+// headers, without decompressing the response, and returns the response with
+// its body.
+func (h *testHarness) request(path string, header map[string]string) (*http.Response, []byte) {
+	h.t.Helper()
+	req, err := http.NewRequest(http.MethodGet, h.web.URL+path, nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		h.t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp, body
+}
+
+// TestCachedResponses ensures that the home page and JSON API are served with
+// validators which only change when the voting information is updated, and
+// negotiate gzip and brotli compression.
+func TestCachedResponses(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	// The ETags are derived from the content, so the paths requested are
+	// those which change with every block.
+	for _, path := range []string{"/", "/api/v1/pow"} {
+		resp, plain := h.request(path, nil)
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if resp.StatusCode != http.StatusOK || etag == "" || lastModified == "" {
+			t.Fatalf("GET %s: status %d, ETag %q, Last-Modified %q", path,
+				resp.StatusCode, etag, lastModified)
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("GET %s: Cache-Control %q, want no-cache", path, cc)
+		}
+
+		// The same snapshot is served the same representation.
+		resp, again := h.request(path, nil)
+		if resp.Header.Get("ETag") != etag || !bytes.Equal(again, plain) {
+			t.Errorf("GET %s: response changed without an update", path)
+		}
+
+		resp, compressed := h.request(path, map[string]string{"Accept-Encoding": "gzip"})
+		if resp.Header.Get("Content-Encoding") != "gzip" {
+			t.Fatalf("GET %s: response not compressed", path)
+		}
+		gzipETag := resp.Header.Get("ETag")
+		if gzipETag == etag || !strings.Contains(resp.Header.Get("Vary"), "Accept-Encoding") {
+			t.Errorf("GET %s: compressed ETag %q, Vary %q", path, gzipETag,
+				resp.Header.Get("Vary"))
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decompressed, err := io.ReadAll(zr); err != nil || !bytes.Equal(decompressed, plain) {
+			t.Errorf("GET %s: decompressed body differs (%v)", path, err)
+		}
+
+		// Brotli is preferred unless gzip is given a higher quality.
+		resp, compressed = h.request(path, map[string]string{"Accept-Encoding": "gzip, br"})
+		brETag := resp.Header.Get("ETag")
+		if resp.Header.Get("Content-Encoding") != "br" || brETag == etag || brETag == gzipETag {
+			t.Fatalf("GET %s: Content-Encoding %q, ETag %q", path,
+				resp.Header.Get("Content-Encoding"), brETag)
+		}
+		if decompressed, err := io.ReadAll(brotli.NewReader(bytes.NewReader(compressed))); err != nil ||
+			!bytes.Equal(decompressed, plain) {
+			t.Errorf("GET %s: decompressed body differs (%v)", path, err)
+		}
+		resp, _ = h.request(path, map[string]string{"Accept-Encoding": "gzip, br;q=0.5"})
+		if resp.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("GET %s: Content-Encoding %q, want gzip", path,
+				resp.Header.Get("Content-Encoding"))
+		}
+
+		for _, header := range []map[string]string{
+			{"If-None-Match": etag},
+			{"If-None-Match": gzipETag, "Accept-Encoding": "gzip"},
+			{"If-None-Match": brETag, "Accept-Encoding": "br"},
+			{"If-Modified-Since": lastModified},
+		} {
+			if resp, _ := h.request(path, header); resp.StatusCode != http.StatusNotModified {
+				t.Errorf("GET %s with %v: status %d, want %d", path, header,
+					resp.StatusCode, http.StatusNotModified)
+			}
+		}
+
+		// An update is served to clients revalidating the previous one.
+		h.mineTo(templateInformation.Load().BlockHeight+1, 12, 11, votes(5, 12, 0))
+		for _, header := range []map[string]string{
+			{"If-None-Match": etag},
+			{"If-Modified-Since": lastModified},
+		} {
+			resp, _ := h.request(path, header)
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+				t.Errorf("GET %s with %v after update: status %d, ETag %q", path,
+					header, resp.StatusCode, resp.Header.Get("ETag"))
+			}
+		}
+	}
+}
+
+// TestFingerprintedAssets ensures that the home page links to static assets
+// by URLs with the fingerprint of their content, which may be cached
+// indefinitely, while other URLs must be revalidated.
+func TestFingerprintedAssets(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	_, page := h.request("/", nil)
+	m := regexp.MustCompile(`"(/css/styles\.css)\?v=([0-9a-f]+)"`).FindSubmatch(page)
+	if m == nil {
+		t.Fatalf("home page does not link to a fingerprinted /css/styles.css")
+	}
+	path, fingerprint := string(m[1]), string(m[2])
+
+	tests := []struct {
+		url          string
+		cacheControl string
+	}{
+		{path + "?v=" + fingerprint, immutableCacheControl},
+		{path, "no-cache"},
+		{path + "?v=outdated", "no-cache"},
+	}
+	for _, test := range tests {
+		resp, _ := h.request(test.url, map[string]string{"Accept-Encoding": "gzip"})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d", test.url, resp.StatusCode)
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != test.cacheControl {
+			t.Errorf("GET %s: Cache-Control %q, want %q", test.url, cc, test.cacheControl)
+		}
+		if resp.Header.Get("Content-Encoding") != "gzip" || resp.Header.Get("ETag") == "" {
+			t.Errorf("GET %s: Content-Encoding %q, ETag %q", test.url,
+				resp.Header.Get("Content-Encoding"), resp.Header.Get("ETag"))
+		}
+	}
+
+	// Images in compressed formats are not compressed again, and directories
+	// are not listed.
+	resp, _ := h.request("/images/logo.svg", map[string]string{"Accept-Encoding": "gzip"})
+	if resp.Header.Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("/images/logo.svg: Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+	resp, _ = h.request("/images/og-logo.png", map[string]string{"Accept-Encoding": "gzip"})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("/images/og-logo.png: status %d, Content-Encoding %q",
+			resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+	if resp, _ := h.request("/images/favicon", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("/images/favicon: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
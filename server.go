@@ -0,0 +1,263 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// readHeaderTimeout, readTimeout, writeTimeout and idleTimeout bound
+	// the time spent on each request and idle connection, so that slow or
+	// stalled clients can not exhaust the server's resources.
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = time.Minute
+	idleTimeout       = 2 * time.Minute
+
+	// shutdownTimeout is how long in-flight requests are given to complete
+	// when shutting down.
+	shutdownTimeout = 15 * time.Second
+
+	// unixListenPrefix marks a listen address as the path of a unix socket.
+	unixListenPrefix = "unix:"
+
+	// certCheckInterval is the minimum time between checks of whether the
+	// TLS certificate files have been modified.
+	certCheckInterval = time.Second
+)
+
+// webServer serves HTTP requests on one or more listeners until its context is
+// canceled.
+type webServer struct {
+	srv *http.Server
+	// listeners are served over TLS when useTLS is set, except for unix
+	// sockets which are always plain HTTP.  srv.TLSConfig can not be used
+	// to tell, as serving HTTP/2 may set it.
+	listeners []net.Listener
+	useTLS    bool
+}
+
+// newWebServer binds every address in cfg.Listen, so that any which can not be
+// bound are reported at startup, and returns a server which serves handler on
+// them.
+func newWebServer(cfg *config, handler http.Handler) (*webServer, error) {
+	s := &webServer{
+		srv: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
+		},
+	}
+
+	if cfg.TLSCert != "" {
+		certs, err := newCertReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		s.srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.getCertificate,
+		}
+		s.useTLS = true
+	}
+
+	for _, addr := range cfg.Listen {
+		l, err := listen(addr)
+		if err != nil {
+			s.closeListeners()
+			return nil, err
+		}
+		s.listeners = append(s.listeners, l)
+	}
+	return s, nil
+}
+
+// listen listens on a TCP address, or on a unix socket if addr has the unix:
+// prefix.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixListenPrefix)
+	if !ok {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return l, nil
+	}
+
+	// A socket left behind by a previous process which did not shut down
+	// cleanly prevents listening again, so it is removed.  Any other kind
+	// of file is left alone.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket %s: %w", path, err)
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on unix socket %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// onShutdown registers f to be called when the server starts shutting down,
+// to end long-lived requests which would otherwise delay it.
+func (s *webServer) onShutdown(f func()) {
+	s.srv.RegisterOnShutdown(f)
+}
+
+func (s *webServer) closeListeners() {
+	for _, l := range s.listeners {
+		l.Close()
+	}
+}
+
+// run serves requests until ctx is canceled, and then shuts down gracefully,
+// waiting up to shutdownTimeout for in-flight requests to complete.  It
+// returns the first error which stopped a listener from serving.
+func (s *webServer) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(s.listeners))
+	for _, l := range s.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if s.useTLS && l.Addr().Network() != "unix" {
+				log.Printf("Starting webserver on https://%v", l.Addr())
+				err = s.srv.ServeTLS(l, "", "")
+			} else {
+				log.Printf("Starting webserver on %v", l.Addr())
+				err = s.srv.Serve(l)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("webserver on %v failed: %w", l.Addr(), err)
+				cancel()
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	log.Println("Shutting down webserver")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := s.srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Webserver did not shut down cleanly: %v", err)
+		s.srv.Close()
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// certReloader provides the TLS certificate loaded from a certificate and key
+// file, and reloads it whenever either file is modified.  The files are
+// checked at most once per checkInterval, rather than on every handshake.
+type certReloader struct {
+	certFile, keyFile string
+	checkInterval     time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+	// failing is set while the certificate can not be reloaded, so that
+	// the failure is only logged once.
+	failing bool
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: certCheckInterval,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	r.lastCheck = time.Now()
+	return r, nil
+}
+
+// modTimes returns the modification times of the certificate and key files.
+func (r *certReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// reload loads the certificate if either file has been modified since it was
+// last loaded.  The caller must hold r.mu, or have exclusive access to r.
+func (r *certReloader) reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	if r.cert != nil && certMod.Equal(r.certMod) && keyMod.Equal(r.keyMod) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if r.cert != nil {
+		log.Printf("Reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cert, r.certMod, r.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+// getCertificate is the tls.Config GetCertificate callback.  A certificate
+// which fails to reload is logged once, until it is reloaded, and the previous
+// certificate continues to be served, since the certificate and key files are
+// not replaced atomically.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.lastCheck) < r.checkInterval {
+		return r.cert, nil
+	}
+	r.lastCheck = time.Now()
+
+	err := r.reload()
+	switch {
+	case err != nil && !r.failing:
+		log.Printf("%v (serving the previous certificate)", err)
+		r.failing = true
+	case err == nil && r.failing:
+		log.Printf("TLS certificate files %s and %s are readable again",
+			r.certFile, r.keyFile)
+		r.failing = false
+	}
+	return r.cert, nil
+}
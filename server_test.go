@@ -0,0 +1,311 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startWebServer starts a web server for cfg serving handler, and returns it
+// along with a function which shuts it down and returns the error from run.
+func startWebServer(t *testing.T, cfg *config, handler http.Handler) (*webServer, func() error) {
+	t.Helper()
+	s, err := newWebServer(cfg, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.run(ctx) }()
+	stop := sync.OnceValue(func() error {
+		cancel()
+		return <-done
+	})
+	t.Cleanup(func() { stop() })
+	return s, stop
+}
+
+// TestWebServerGracefulShutdown ensures that shutting down the web server
+// waits for in-flight requests to complete, and stops accepting new ones.
+func TestWebServerGracefulShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	s, stop := startWebServer(t, &config{Listen: []string{"127.0.0.1:0"}}, handler)
+	url := "http://" + s.listeners[0].Addr().String() + "/"
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- stop() }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("server stopped with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if b := <-body; b != "done" {
+		t.Fatalf("in-flight request response %q, want done", b)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Fatalf("request succeeded after shutdown")
+	}
+}
+
+// TestWebServerListeners ensures that the web server serves every listen
+// address, including unix sockets, and replaces stale unix sockets.
+func TestWebServerListeners(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "dcrvotingweb.sock")
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave the socket file behind, as a process which was killed would.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	s, _ := startWebServer(t, &config{Listen: []string{"127.0.0.1:0",
+		"127.0.0.1:0", unixListenPrefix + socket}}, handler)
+	if len(s.listeners) != 3 {
+		t.Fatalf("%d listeners, want 3", len(s.listeners))
+	}
+
+	for _, l := range s.listeners {
+		addr := l.Addr()
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, addr.Network(), addr.String())
+			},
+		}}
+		resp, err := client.Get("http://dcrvotingweb/")
+		if err != nil {
+			t.Fatalf("%v: %v", addr, err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "ok" {
+			t.Errorf("%v: response %q, want ok", addr, b)
+		}
+	}
+
+	// A listen address which is already in use is reported.
+	_, err = newWebServer(&config{Listen: []string{s.listeners[0].Addr().String()}}, handler)
+	if err == nil {
+		t.Errorf("listening on an address in use succeeded")
+	}
+}
+
+// writeCert writes a new self-signed certificate for 127.0.0.1 and its key to
+// certFile and keyFile, and returns the certificate.
+func writeCert(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "dcrvotingweb"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// TestWebServerTLSReload ensures that the web server serves HTTPS with the
+// configured certificate, and serves a new certificate once the files are
+// replaced.
+func TestWebServerTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.cert"), filepath.Join(dir, "tls.key")
+	first := writeCert(t, certFile, keyFile, 1)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	s, _ := startWebServer(t, &config{
+		Listen:  []string{"127.0.0.1:0"},
+		TLSCert: certFile,
+		TLSKey:  keyFile,
+	}, handler)
+	url := "https://" + s.listeners[0].Addr().String() + "/"
+
+	// servedSerial returns the serial number of the certificate served to a
+	// new connection which trusts cert.
+	servedSerial := func(cert *x509.Certificate) int64 {
+		t.Helper()
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	if serial := servedSerial(first); serial != 1 {
+		t.Fatalf("served certificate %d, want 1", serial)
+	}
+
+	// The modification times are set explicitly so that the replacement is
+	// detected on file systems with coarse timestamps.  The files are only
+	// checked once per interval.
+	second := writeCert(t, certFile, keyFile, 2)
+	modTime := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(certCheckInterval)
+	if serial := servedSerial(second); serial != 2 {
+		t.Fatalf("served certificate %d after replacement, want 2", serial)
+	}
+
+	// An invalid replacement is ignored.
+	if err := os.WriteFile(keyFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(certCheckInterval)
+	if serial := servedSerial(second); serial != 2 {
+		t.Fatalf("served certificate %d after invalid replacement, want 2", serial)
+	}
+}
+
+// TestCertReloaderChecks ensures that the certificate files are only checked
+// once per interval, and that a failure to reload them is logged once until
+// they can be loaded again.
+func TestCertReloaderChecks(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.cert"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, 1)
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := func() int64 {
+		t.Helper()
+		cert, err := r.getCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.SerialNumber.Int64()
+	}
+
+	var logged bytes.Buffer
+	prevOutput := log.Writer()
+	t.Cleanup(func() { log.SetOutput(prevOutput) })
+	log.SetOutput(&logged)
+
+	// A replacement within the interval is not seen until it has passed.
+	writeCert(t, certFile, keyFile, 2)
+	modTime := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if serial := served(); serial != 1 {
+		t.Fatalf("served certificate %d within the interval, want 1", serial)
+	}
+	r.checkInterval = 0
+	if serial := served(); serial != 2 {
+		t.Fatalf("served certificate %d after the interval, want 2", serial)
+	}
+
+	// Missing files are logged once, however many handshakes there are.
+	if err := os.Rename(keyFile, keyFile+".old"); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if serial := served(); serial != 2 {
+			t.Fatalf("served certificate %d without files, want 2", serial)
+		}
+	}
+	if n := strings.Count(logged.String(), "failed to read TLS certificate"); n != 1 {
+		t.Errorf("failure logged %d times, want 1:\n%s", n, logged.String())
+	}
+	if err := os.Rename(keyFile+".old", keyFile); err != nil {
+		t.Fatal(err)
+	}
+	served()
+	if !strings.Contains(logged.String(), "readable again") {
+		t.Errorf("recovery not logged:\n%s", logged.String())
+	}
+}
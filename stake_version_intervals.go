@@ -1,4 +1,4 @@
-// Copyright (c) 2017-2023 The Decred developers
+// Copyright (c) 2017-2025 The Decred developers
 // Use of this source code is governed by an ISC
 // license that can be found in the LICENSE file.
 
@@ -6,11 +6,10 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 
-	"github.com/decred/dcrd/chaincfg/v3"
 	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
-	"github.com/decred/dcrd/rpcclient/v8"
 )
 
 // StakeVersionIntervals wraps a set of types.VersionIntervals
@@ -20,30 +19,61 @@ type StakeVersionIntervals struct {
 	MaxVoteVersion uint32
 }
 
-// GetStakeVersionUpgradeSVI will search through every stake version interval
-// to find the first SVI which meets the upgrade threshold for the provided version.
-func (s *StakeVersionIntervals) GetStakeVersionUpgradeSVI(version uint32) (upgradeOccurred bool, upgradeSVI types.VersionInterval) {
-
-	// This site is not able to cope with the PoS upgrade threshold being met
-	// before the PoW threshold.
-	// That happened on both testnet and mainnet during the version 8 upgrade,
-	// and on mainnet during version 9.
-	// Hardcoding those upgrade SVIs rather than detecting them
-	// programmatically.
-	if version == 8 {
-		switch activeNetParams.Name {
-		case chaincfg.MainNetParams().Name:
-			return true, s.Intervals[261]
-		case chaincfg.TestNet3Params().Name:
-			return true, s.Intervals[152]
-		default:
-			panic("unsupported network")
+// blockVersionsFunc returns the number of blocks of each block version in the
+// PoW rolling window ending with the block at the provided height.
+type blockVersionsFunc func(height int64) (map[int32]int64, error)
+
+// blockVersionWindows returns a blockVersionsFunc which requests the PoW
+// rolling windows from the chain source, caching those which are final below
+// the tip at currentHeight.
+func blockVersionWindows(ctx context.Context, chain chainSource, cache *voteCache, currentHeight int64) blockVersionsFunc {
+	return func(height int64) (map[int32]int64, error) {
+		hash, err := chain.GetBlockHash(ctx, height)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlockHash error: %w", err)
 		}
+
+		cacheable := isFinal(height, currentHeight)
+		if cacheable {
+			if counts, ok := cache.blockVersions(hash.String()); ok {
+				return counts, nil
+			}
+		}
+
+		count := min(int64(activeNetParams.BlockUpgradeNumToCheck), height+1)
+		stakeVersions, err := chain.GetStakeVersions(ctx, hash.String(), int32(count))
+		if err != nil {
+			return nil, fmt.Errorf("GetStakeVersions error: %w", err)
+		}
+		counts := make(map[int32]int64)
+		for _, sv := range stakeVersions.StakeVersions {
+			counts[sv.BlockVersion]++
+		}
+
+		if cacheable {
+			cache.storeBlockVersions(hash.String(), height, counts)
+		}
+		return counts, nil
 	}
-	if version == 9 && activeNetParams.Name == chaincfg.MainNetParams().Name {
-		return true, s.Intervals[312]
-	}
+}
+
+// GetStakeVersionUpgradeSVI will search through every stake version interval
+// to find the SVI in which the upgrade to the provided version took place.
+//
+// The upgrade requires a majority of the votes cast during the SVI to be for
+// the version, and the PoW upgrade to the block version produced by the same
+// software release to be complete at the end of the SVI.  The PoS threshold
+// can be met before the PoW threshold, as happened on both testnet and mainnet
+// during the version 8 upgrade, in which case the upgrade takes place in the
+// first later SVI which meets both.
+func (s *StakeVersionIntervals) GetStakeVersionUpgradeSVI(version uint32,
+	blockVersions blockVersionsFunc) (upgradeOccurred bool, upgradeSVI types.VersionInterval, err error) {
 
+	// requiredBlockVersion is the block version produced by the software
+	// release which votes for the version.  For the newest deployment this
+	// is the expected block version.  Older releases are identified by the
+	// newest block version being mined when the PoS threshold is first met.
+	var requiredBlockVersion int32
 	for i, svi := range s.Intervals {
 		// If this is an incomplete SVI, then the upgrade has not happened.
 		if svi.EndHeight-svi.StartHeight < activeNetParams.StakeVersionInterval {
@@ -60,46 +90,88 @@ func (s *StakeVersionIntervals) GetStakeVersionUpgradeSVI(version uint32) (upgra
 			}
 		}
 		upgradeThreshold := totalVotes * activeNetParams.StakeMajorityMultiplier / activeNetParams.StakeMajorityDivisor
-		if versionVotes > upgradeThreshold {
-			log.Printf("v%d upgrade threshold was met during SVI %d (blocks %d-%d). Total votes: %d, v%d votes: %d, threshold: %d",
-				version, i+1, svi.StartHeight, svi.EndHeight, totalVotes, version, versionVotes, upgradeThreshold)
-			return true, svi
+		if versionVotes <= upgradeThreshold {
+			continue
+		}
+
+		// Check the PoW rolling window ending with the final block of the
+		// SVI.
+		counts, err := blockVersions(svi.EndHeight - 1)
+		if err != nil {
+			return false, types.VersionInterval{}, err
+		}
+		if requiredBlockVersion == 0 {
+			if version == newestDeploymentVersion() {
+				requiredBlockVersion = expectedBlockVersion(counts)
+			} else {
+				requiredBlockVersion = newestBlockVersion(counts)
+			}
+		}
+		var upgradedBlocks int64
+		for v, n := range counts {
+			if v >= requiredBlockVersion {
+				upgradedBlocks += n
+			}
+		}
+		if upgradedBlocks < int64(activeNetParams.BlockRejectNumRequired) {
+			log.Printf("v%d PoS upgrade threshold was met during SVI %d (blocks %d-%d), but the PoW upgrade to "+
+				"block version %d was not complete (%d blocks, threshold: %d)", version, i+1, svi.StartHeight,
+				svi.EndHeight, requiredBlockVersion, upgradedBlocks, activeNetParams.BlockRejectNumRequired)
+			continue
 		}
+
+		log.Printf("v%d upgrade threshold was met during SVI %d (blocks %d-%d). Total votes: %d, v%d votes: %d, threshold: %d",
+			version, i+1, svi.StartHeight, svi.EndHeight, totalVotes, version, versionVotes, upgradeThreshold)
+		return true, svi, nil
 	}
-	return false, types.VersionInterval{}
+	return false, types.VersionInterval{}, nil
 }
 
-// AllStakeVersionIntervals uses the dcrd client to create an ordered
+// AllStakeVersionIntervals uses the chain source to create an ordered
 // set of objects representing every Stake Version Interval up to the
-// provided block height
-func AllStakeVersionIntervals(ctx context.Context, dcrdClient *rpcclient.Client, height int64) (StakeVersionIntervals, error) {
+// provided block height. Intervals found in the cache are not requested
+// from the chain source again.
+func AllStakeVersionIntervals(ctx context.Context, chain chainSource, cache *voteCache, height int64) (StakeVersionIntervals, error) {
 	// Use current height to calculate the number of the current SVI
 	totalSVIs := 1 + int32((height-activeNetParams.StakeValidationHeight)/activeNetParams.StakeVersionInterval)
 
-	// Get SVIs details from dcrd
-	stakeVersionInfoResult, err := dcrdClient.GetStakeVersionInfo(ctx, totalSVIs)
+	cached, err := cache.intervals(ctx, chain)
 	if err != nil {
 		return StakeVersionIntervals{}, err
 	}
 
-	svis := StakeVersionIntervals{
-		Intervals: stakeVersionInfoResult.Intervals,
+	// Get details of the SVIs which are not cached from dcrd
+	stakeVersionInfoResult, err := chain.GetStakeVersionInfo(ctx, totalSVIs-int32(len(cached)))
+	if err != nil {
+		return StakeVersionIntervals{}, err
 	}
+	fetched := stakeVersionInfoResult.Intervals
 
 	// Reverse the slice of SVIs
 	// This makes traversing the set easier later on,
 	// because the first element is the first SVI, etc.
-	for i := len(svis.Intervals)/2 - 1; i >= 0; i-- {
-		opp := len(svis.Intervals) - 1 - i
-		svis.Intervals[i], svis.Intervals[opp] = svis.Intervals[opp], svis.Intervals[i]
+	for i := len(fetched)/2 - 1; i >= 0; i-- {
+		opp := len(fetched) - 1 - i
+		fetched[i], fetched[opp] = fetched[opp], fetched[i]
 	}
 
-	// Get max vote version
-	var max uint32
-	for version := range activeNetParams.Deployments {
-		if version > max {
-			max = version
-		}
+	// The fetched SVIs must continue on from the last cached SVI. If they
+	// do not, the cache is unusable so fetch every SVI instead.
+	if len(cached) > 0 && (len(fetched) == 0 ||
+		fetched[0].StartHeight != cached[len(cached)-1].EndHeight) {
+
+		log.Printf("Cached stake version intervals do not match dcrd, discarding")
+		cache.resetIntervals()
+		return AllStakeVersionIntervals(ctx, chain, cache, height)
+	}
+
+	svis := StakeVersionIntervals{
+		Intervals: append(cached, fetched...),
+	}
+
+	err = cache.storeIntervals(ctx, chain, svis.Intervals, height)
+	if err != nil {
+		return StakeVersionIntervals{}, err
 	}
 
 	min := activeNetParams.GenesisBlock.Header.StakeVersion
@@ -107,7 +179,7 @@ func AllStakeVersionIntervals(ctx context.Context, dcrdClient *rpcclient.Client,
 		min = 4
 	}
 	svis.MinVoteVersion = min
-	svis.MaxVoteVersion = max
+	svis.MaxVoteVersion = newestDeploymentVersion()
 
 	return svis, nil
 }
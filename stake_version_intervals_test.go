@@ -0,0 +1,284 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+)
+
+func TestGetStakeVersionUpgradeSVI(t *testing.T) {
+	prevParams := activeNetParams
+	t.Cleanup(func() { activeNetParams = prevParams })
+	activeNetParams = testNetParams()
+
+	// interval returns a stake version interval starting at start with the
+	// provided vote version counts. The final argument determines whether the
+	// interval is complete.
+	interval := func(start int64, complete bool, counts ...types.VersionCount) types.VersionInterval {
+		end := start + activeNetParams.StakeVersionInterval
+		if !complete {
+			end = start + 10
+		}
+		return types.VersionInterval{
+			StartHeight:  start,
+			EndHeight:    end,
+			VoteVersions: counts,
+		}
+	}
+
+	// upgraded is the PoW rolling window once the block version upgrade is
+	// complete.
+	window := int64(activeNetParams.BlockUpgradeNumToCheck)
+	upgraded := map[int32]int64{12: window}
+
+	tests := []struct {
+		name      string
+		intervals []types.VersionInterval
+		// windows are the block versions in the PoW rolling windows ending
+		// at each height.  Any other window is upgraded.
+		windows   map[int64]map[int32]int64
+		wantOK    bool
+		wantStart int64
+	}{{
+		name: "no intervals",
+	}, {
+		name: "threshold not met",
+		intervals: []types.VersionInterval{
+			interval(144, true, types.VersionCount{Version: 11, Count: 560}),
+			interval(256, true,
+				types.VersionCount{Version: 11, Count: 300},
+				types.VersionCount{Version: 12, Count: 260}),
+		},
+	}, {
+		name: "exactly at threshold is not enough",
+		intervals: []types.VersionInterval{
+			interval(144, true,
+				types.VersionCount{Version: 11, Count: 140},
+				types.VersionCount{Version: 12, Count: 420}),
+		},
+	}, {
+		name: "incomplete interval is ignored",
+		intervals: []types.VersionInterval{
+			interval(144, true, types.VersionCount{Version: 11, Count: 560}),
+			interval(256, false, types.VersionCount{Version: 12, Count: 50}),
+		},
+	}, {
+		name: "first interval meeting threshold is returned",
+		intervals: []types.VersionInterval{
+			interval(144, true, types.VersionCount{Version: 11, Count: 560}),
+			interval(256, true,
+				types.VersionCount{Version: 11, Count: 139},
+				types.VersionCount{Version: 12, Count: 421}),
+			interval(368, true, types.VersionCount{Version: 12, Count: 560}),
+		},
+		wantOK:    true,
+		wantStart: 256,
+	}, {
+		name: "PoS threshold met before PoW threshold",
+		intervals: []types.VersionInterval{
+			interval(144, true, types.VersionCount{Version: 12, Count: 560}),
+			interval(256, true, types.VersionCount{Version: 12, Count: 560}),
+			interval(368, true, types.VersionCount{Version: 12, Count: 560}),
+		},
+		windows: map[int64]map[int32]int64{
+			255: {11: window * 9 / 10, 12: window / 10},
+			367: {11: window * 3 / 10, 12: window * 7 / 10},
+		},
+		wantOK:    true,
+		wantStart: 368,
+	}, {
+		name: "PoS threshold lost before PoW threshold met",
+		intervals: []types.VersionInterval{
+			interval(144, true, types.VersionCount{Version: 12, Count: 560}),
+			interval(256, true,
+				types.VersionCount{Version: 11, Count: 300},
+				types.VersionCount{Version: 12, Count: 260}),
+			interval(368, true, types.VersionCount{Version: 12, Count: 560}),
+		},
+		windows: map[int64]map[int32]int64{
+			255: {11: window / 2, 12: window / 2},
+		},
+		wantOK:    true,
+		wantStart: 368,
+	}, {
+		name: "PoW threshold not met",
+		intervals: []types.VersionInterval{
+			interval(144, true, types.VersionCount{Version: 12, Count: 560}),
+			interval(256, true, types.VersionCount{Version: 12, Count: 560}),
+		},
+		windows: map[int64]map[int32]int64{
+			255: {11: window / 2, 12: window / 2},
+			367: {11: window * 3 / 10, 12: window * 7 / 10},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svis := StakeVersionIntervals{Intervals: test.intervals}
+			blockVersions := func(height int64) (map[int32]int64, error) {
+				if counts, ok := test.windows[height]; ok {
+					return counts, nil
+				}
+				return upgraded, nil
+			}
+			ok, svi, err := svis.GetStakeVersionUpgradeSVI(12, blockVersions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != test.wantOK {
+				t.Fatalf("upgrade occurred %v, want %v", ok, test.wantOK)
+			}
+			if ok && svi.StartHeight != test.wantStart {
+				t.Fatalf("upgrade interval starts at %d, want %d",
+					svi.StartHeight, test.wantStart)
+			}
+		})
+	}
+}
+
+// TestGetStakeVersionUpgradeSVINetworks ensures the upgrade interval is
+// detected from the chain on every network, including when the PoS threshold
+// is met before the PoW threshold.
+func TestGetStakeVersionUpgradeSVINetworks(t *testing.T) {
+	prevParams := activeNetParams
+	t.Cleanup(func() { activeNetParams = prevParams })
+
+	for _, params := range []*chaincfg.Params{chaincfg.MainNetParams(),
+		chaincfg.TestNet3Params(), chaincfg.SimNetParams(), chaincfg.RegNetParams()} {
+
+		t.Run(params.Name, func(t *testing.T) {
+			activeNetParams = params
+			start := params.StakeValidationHeight
+			svis := StakeVersionIntervals{Intervals: []types.VersionInterval{{
+				StartHeight:  start,
+				EndHeight:    start + params.StakeVersionInterval,
+				VoteVersions: []types.VersionCount{{Version: 10, Count: 10}},
+			}, {
+				StartHeight:  start + params.StakeVersionInterval,
+				EndHeight:    start + 2*params.StakeVersionInterval,
+				VoteVersions: []types.VersionCount{{Version: 10, Count: 10}},
+			}}}
+			window := int64(params.BlockUpgradeNumToCheck)
+			blockVersions := func(height int64) (map[int32]int64, error) {
+				if height < start+params.StakeVersionInterval {
+					return map[int32]int64{5: window / 2, 6: window / 2}, nil
+				}
+				return map[int32]int64{6: window}, nil
+			}
+			wantStart := start + params.StakeVersionInterval
+			ok, svi, err := svis.GetStakeVersionUpgradeSVI(10, blockVersions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok || svi.StartHeight != wantStart {
+				t.Fatalf("upgrade occurred %v at %d, want true at %d", ok,
+					svi.StartHeight, wantStart)
+			}
+		})
+	}
+}
+
+// TestGetStakeVersionUpgradeSVIPublicNetworks ensures the upgrades during
+// which the PoS threshold was met before the PoW threshold on the public
+// networks are detected in the SVI which met both, with the interval lengths
+// and PoW rolling windows of those networks.  The votes and block versions are
+// synthetic: the PoS threshold is met a few SVIs before the PoW upgrade
+// completes at the end of the SVI the upgrade took place in.
+func TestGetStakeVersionUpgradeSVIPublicNetworks(t *testing.T) {
+	prevParams := activeNetParams
+	t.Cleanup(func() { activeNetParams = prevParams })
+
+	tests := []struct {
+		params    *chaincfg.Params
+		version   uint32
+		intervals int
+		wantOK    bool
+		wantIndex int
+	}{
+		{chaincfg.MainNetParams(), 8, 400, true, 261},
+		{chaincfg.MainNetParams(), 9, 400, true, 312},
+		{chaincfg.TestNet3Params(), 8, 400, true, 152},
+		// The chain has not been synced up to the upgrade.
+		{chaincfg.MainNetParams(), 8, 261, false, 0},
+	}
+	for _, test := range tests {
+		params := test.params
+		activeNetParams = params
+		blockVersion := int32(test.version)
+		posIndex := test.wantIndex - 4
+		if !test.wantOK {
+			posIndex = test.intervals - 4
+		}
+
+		svis := StakeVersionIntervals{
+			Intervals: make([]types.VersionInterval, test.intervals),
+		}
+		for i := range svis.Intervals {
+			start := params.StakeValidationHeight + int64(i)*params.StakeVersionInterval
+			votes := []types.VersionCount{{Version: test.version - 1, Count: 10000}}
+			if i >= posIndex {
+				votes = []types.VersionCount{
+					{Version: test.version - 1, Count: 1000},
+					{Version: test.version, Count: 9000},
+				}
+			}
+			svis.Intervals[i] = types.VersionInterval{
+				StartHeight:  start,
+				EndHeight:    start + params.StakeVersionInterval,
+				VoteVersions: votes,
+			}
+		}
+
+		// The share of upgraded blocks grows each SVI from the one the PoS
+		// threshold is met in, reaching the PoW threshold at the end of the
+		// upgrade SVI.
+		window := int64(params.BlockUpgradeNumToCheck)
+		blockVersions := func(height int64) (map[int32]int64, error) {
+			i := int((height - params.StakeValidationHeight) / params.StakeVersionInterval)
+			upgraded := int64(params.BlockRejectNumRequired) - int64(posIndex+4-i)*window/10
+			if i >= posIndex+4 {
+				upgraded = window
+			}
+			upgraded = max(0, upgraded)
+			return map[int32]int64{blockVersion - 1: window - upgraded,
+				blockVersion: upgraded}, nil
+		}
+
+		ok, svi, err := svis.GetStakeVersionUpgradeSVI(test.version, blockVersions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != test.wantOK || (ok && svi.StartHeight != svis.Intervals[test.wantIndex].StartHeight) {
+			t.Errorf("%s v%d: upgrade occurred %v at %d, want %v at SVI %d",
+				params.Name, test.version, ok, svi.StartHeight, test.wantOK,
+				test.wantIndex)
+		}
+	}
+}
+
+// TestGetStakeVersionUpgradeSVIError ensures errors fetching the PoW rolling
+// window are returned.
+func TestGetStakeVersionUpgradeSVIError(t *testing.T) {
+	prevParams := activeNetParams
+	t.Cleanup(func() { activeNetParams = prevParams })
+	activeNetParams = testNetParams()
+
+	svis := StakeVersionIntervals{Intervals: []types.VersionInterval{{
+		StartHeight:  144,
+		EndHeight:    144 + activeNetParams.StakeVersionInterval,
+		VoteVersions: []types.VersionCount{{Version: 12, Count: 560}},
+	}}}
+	wantErr := errors.New("dcrd unavailable")
+	_, _, err := svis.GetStakeVersionUpgradeSVI(12, func(int64) (map[int32]int64, error) {
+		return nil, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("error %v, want %v", err, wantErr)
+	}
+}
@@ -5,6 +5,9 @@
 package main
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
 )
 
@@ -65,15 +68,63 @@ type templateFields struct {
 	StakeVersionMostPopularPercentage float64
 	// StakeVersionTimeRemaining is a string to show how much estimated time is remaining in the stake version interval.
 	StakeVersionTimeRemaining string
+	// SVIParticipation is the participation in each of the stake version
+	// intervals of StakeVersionsIntervals, oldest first.
+	SVIParticipation []Participation
+	// CurrentSVIVoters is the number of votes in each block of the current
+	// stake version interval, ending at the main chain tip.
+	CurrentSVIVoters []int64
 
 	// Length of the static rule change interval
 	RuleChangeActivationInterval int64
 	// Agendas contains all the agendas and their statuses
 	Agendas []Agenda
+	// RCIParticipation is the participation in each voting window agendas
+	// have been voted on in, oldest first.
+	RCIParticipation []Participation
 	// Phase Upgrading or Voting
 	IsUpgrading bool
 	// Pending Activation to show that voting has ceased and activation will begin shortly
 	PendingActivation bool
 	// Rules Activated to show that all rules have activated
 	RulesActivated bool
+	// TSpends are the treasury spends being voted on, followed by those
+	// which have been mined or have expired.  It is nil until the treasury
+	// agenda has activated.
+	TSpends []TSpend
+
+	// Reorgs is the number of chain reorganizations seen since startup.
+	Reorgs uint64
+	// SkippedUpdates is the number of block notifications since startup
+	// which were coalesced into the update for a later block.
+	SkippedUpdates uint64
+	// CatalogReloads is the number of times the agenda catalog has been
+	// reloaded since startup.
+	CatalogReloads uint64
+
+	// BackendAvailable is whether dcrd is currently connected.
+	BackendAvailable bool
+	// BackendError describes why dcrd is unavailable.
+	BackendError string
+	// LastUpdate is when the voting information was last successfully
+	// updated. It is zero until the first update.
+	LastUpdate time.Time
+}
+
+// UpdateID identifies the snapshot of the voting information.  It differs for
+// every update, when the agenda catalog is reloaded, and when dcrd becomes
+// unavailable, so clients can tell whether the voting information they were
+// served is current.
+func (t *templateFields) UpdateID() string {
+	if !t.HasData() {
+		return fmt.Sprintf("0-%t", t.BackendAvailable)
+	}
+	return fmt.Sprintf("%d-%d-%d-%t", t.BlockHeight, t.LastUpdate.UnixNano(),
+		t.CatalogReloads, t.BackendAvailable)
+}
+
+// HasData returns whether the voting information has been computed at least
+// once.
+func (t *templateFields) HasData() bool {
+	return !t.LastUpdate.IsZero()
 }
@@ -0,0 +1,410 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/decred/dcrd/blockchain/stake/v5"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v3"
+	"github.com/decred/dcrd/dcrutil/v4"
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+	"github.com/decred/dcrd/wire"
+)
+
+// TSpend statuses.  A tspend is pending until its voting window starts, and
+// approved once enough votes have been cast for it to be mined in the next
+// treasury vote interval block.
+const (
+	tspendPending  = "pending"
+	tspendVoting   = "voting"
+	tspendApproved = "approved"
+	tspendMined    = "mined"
+	tspendExpired  = "expired"
+)
+
+// TSpend is a treasury spend transaction and the votes cast on it by
+// stakeholders.
+type TSpend struct {
+	Hash string
+	// Amount is the total paid out by the tspend, in atoms.
+	Amount int64
+	// Expiry is the height from which the tspend can no longer be mined.
+	Expiry int64
+	// VoteStart and VoteEnd are the heights of the first block of the
+	// voting window and of the first block after it.
+	VoteStart int64
+	VoteEnd   int64
+	Yes       int64
+	No        int64
+	// MinedHeight is the height of the block the tspend was mined in, or
+	// -1 if it has not been mined.
+	MinedHeight int64
+	// Status is one of "pending", "voting", "approved", "mined" or
+	// "expired".
+	Status string
+	// Projection extrapolates the votes to the end of the voting window.
+	// It is nil unless the tspend is being voted on.
+	Projection *TSpendProjection
+}
+
+// TSpendProjection extrapolates the votes cast on a tspend to the end of its
+// voting window.
+type TSpendProjection struct {
+	// Height is the most recent block counted.
+	Height int64
+	// BlocksRemaining is the number of blocks left in the voting window.
+	BlocksRemaining int64
+	// YesPerBlock and NoPerBlock are the average number of yes and no votes
+	// per block since the voting window started.
+	YesPerBlock float64
+	NoPerBlock  float64
+	// FinalApproval is the projected approval rating, as a percentage, at
+	// the end of the voting window.
+	FinalApproval float64
+	// QuorumProjected and Approved are whether the quorum, and both the
+	// quorum and the approval required, are projected to be met by the end
+	// of the voting window.
+	QuorumProjected bool
+	Approved        bool
+	// CanPass is whether the tspend would be approved if every remaining
+	// ticket voted yes.
+	CanPass bool
+}
+
+// AmountDCR returns the amount paid out by the tspend formatted in DCR.
+func (ts *TSpend) AmountDCR() string {
+	return dcrutil.Amount(ts.Amount).String()
+}
+
+// TotalVotes returns the number of yes and no votes cast on the tspend.
+func (ts *TSpend) TotalVotes() int64 {
+	return ts.Yes + ts.No
+}
+
+// QuorumThreshold returns the number of votes which must be cast on the tspend
+// for it to be approved.  It is a share of every vote which may be cast in the
+// voting window.
+func (ts *TSpend) QuorumThreshold() int64 {
+	maxVotes := uint64(activeNetParams.TicketsPerBlock) * uint64(ts.VoteEnd-ts.VoteStart)
+	return int64(maxVotes * activeNetParams.TreasuryVoteQuorumMultiplier /
+		activeNetParams.TreasuryVoteQuorumDivisor)
+}
+
+// QuorumMet indicates if the quorum has been met.
+func (ts *TSpend) QuorumMet() bool {
+	return ts.TotalVotes() >= ts.QuorumThreshold()
+}
+
+// ApprovalRating returns the percentage of the votes which are yes.
+func (ts *TSpend) ApprovalRating() float64 {
+	return 100 * float64(ts.Yes) / float64(ts.TotalVotes())
+}
+
+// RequiredApproval returns the percentage of the votes which must be yes for
+// the tspend to be approved.
+func (ts *TSpend) RequiredApproval() float64 {
+	return tspendRequiredApproval()
+}
+
+// tspendRequiredApproval returns the percentage of the votes which must be yes
+// for a tspend to be approved.
+func tspendRequiredApproval() float64 {
+	return 100 * float64(activeNetParams.TreasuryVoteRequiredMultiplier) /
+		float64(activeNetParams.TreasuryVoteRequiredDivisor)
+}
+
+// IsPending, IsVoting, IsApproved, IsMined and IsExpired report the status of
+// the tspend.
+func (ts *TSpend) IsPending() bool  { return ts.Status == tspendPending }
+func (ts *TSpend) IsVoting() bool   { return ts.Status == tspendVoting }
+func (ts *TSpend) IsApproved() bool { return ts.Status == tspendApproved }
+func (ts *TSpend) IsMined() bool    { return ts.Status == tspendMined }
+func (ts *TSpend) IsExpired() bool  { return ts.Status == tspendExpired }
+
+// InProgress indicates if the tspend has been neither mined nor expired.
+func (ts *TSpend) InProgress() bool {
+	return !ts.IsMined() && !ts.IsExpired()
+}
+
+// settledHeight returns the height the tspend was mined at or expired at.
+func (ts *TSpend) settledHeight() int64 {
+	if ts.IsMined() {
+		return ts.MinedHeight
+	}
+	return ts.Expiry
+}
+
+// tspendApprovedBy returns whether the provided votes on a tspend with the
+// provided quorum would allow it to be mined, using the same integer
+// arithmetic as dcrd.
+func tspendApprovedBy(yes, no, quorum int64) bool {
+	cast := yes + no
+	if cast < quorum {
+		return false
+	}
+	required := uint64(cast) * activeNetParams.TreasuryVoteRequiredMultiplier /
+		activeNetParams.TreasuryVoteRequiredDivisor
+	return uint64(yes) >= required
+}
+
+// project returns the projection of the votes cast so far on the tspend to the
+// end of its voting window.  It must only be called while the voting window is
+// in progress at the provided height.
+func (ts *TSpend) project(height int64) *TSpendProjection {
+	height = min(height, ts.VoteEnd-1)
+	blocks := height - ts.VoteStart + 1
+	proj := &TSpendProjection{
+		Height:          height,
+		BlocksRemaining: ts.VoteEnd - 1 - height,
+		YesPerBlock:     float64(ts.Yes) / float64(blocks),
+		NoPerBlock:      float64(ts.No) / float64(blocks),
+	}
+
+	remaining := float64(proj.BlocksRemaining)
+	finalYes := float64(ts.Yes) + proj.YesPerBlock*remaining
+	finalNo := float64(ts.No) + proj.NoPerBlock*remaining
+	if finalYes+finalNo > 0 {
+		proj.FinalApproval = 100 * finalYes / (finalYes + finalNo)
+	}
+	quorum := ts.QuorumThreshold()
+	proj.QuorumProjected = finalYes+finalNo >= float64(quorum)
+	proj.Approved = tspendApprovedBy(int64(finalYes), int64(finalNo), quorum)
+
+	maxRemaining := proj.BlocksRemaining * int64(activeNetParams.TicketsPerBlock)
+	proj.CanPass = tspendApprovedBy(ts.Yes+maxRemaining, ts.No, quorum)
+	return proj
+}
+
+// setStatus sets the status, and projection, of a tspend which has not been
+// mined, given the height of the main chain tip.
+func (ts *TSpend) setStatus(height int64) {
+	ts.Projection = nil
+	switch {
+	case height >= ts.Expiry:
+		ts.Status = tspendExpired
+	case height < ts.VoteStart:
+		ts.Status = tspendPending
+	case tspendApprovedBy(ts.Yes, ts.No, ts.QuorumThreshold()):
+		ts.Status = tspendApproved
+	default:
+		ts.Status = tspendVoting
+		ts.Projection = ts.project(height)
+	}
+}
+
+// newTSpend returns the tspend of a transaction, without any votes.
+func newTSpend(tx *wire.MsgTx) TSpend {
+	var amount int64
+	// The first output is a null data output randomizing the hash.
+	for _, out := range tx.TxOut[1:] {
+		amount += out.Value
+	}
+	return TSpend{
+		Hash:        tx.TxHash().String(),
+		Amount:      amount,
+		Expiry:      int64(tx.Expiry),
+		MinedHeight: -1,
+	}
+}
+
+// setVotes sets the voting window and votes of a tspend from the
+// gettreasuryspendvotes result.
+func (ts *TSpend) setVotes(votes *types.TreasurySpendVotes) {
+	ts.VoteStart = votes.VoteStart
+	ts.VoteEnd = votes.VoteEnd
+	ts.Yes = votes.YesVotes
+	ts.No = votes.NoVotes
+}
+
+// treasuryActivationHeight returns the height from which tspends may be mined,
+// and false if the treasury agenda has not activated.  Networks which do not
+// vote on the treasury agenda, or on which it was voted on in a vote version
+// older than those being tracked, are assumed to have had it active from the
+// stake validation height.
+func treasuryActivationHeight(agendas []Agenda) (int64, bool) {
+	for i := range agendas {
+		a := &agendas[i]
+		if a.ID != chaincfg.VoteIDTreasury {
+			continue
+		}
+		if !a.IsActive() {
+			return 0, false
+		}
+		return a.ActivationBlock(), true
+	}
+	return activeNetParams.StakeValidationHeight, true
+}
+
+// treasurySpends returns every tspend mined since the treasury agenda
+// activated, along with those in the mempool and those which were seen in the
+// mempool but expired, as of the main chain tip at currentHeight.  They are
+// ordered with those in progress first, by expiry, followed by the others,
+// most recent first.
+func treasurySpends(ctx context.Context, chain chainSource, cache *voteCache,
+	agendas []Agenda, currentHeight int64) ([]TSpend, error) {
+
+	activation, ok := treasuryActivationHeight(agendas)
+	if !ok {
+		return nil, nil
+	}
+
+	tspends, err := minedTSpends(ctx, chain, cache, activation, currentHeight)
+	if err != nil {
+		return nil, err
+	}
+	mined := make(map[string]bool, len(tspends))
+	for _, ts := range tspends {
+		mined[ts.Hash] = true
+	}
+
+	pending, err := mempoolTSpends(ctx, chain, cache, mined)
+	if err != nil {
+		return nil, err
+	}
+	inMempool := make(map[string]bool, len(pending))
+	for _, ts := range pending {
+		inMempool[ts.Hash] = true
+	}
+	tspends = append(tspends, pending...)
+
+	// Tspends which left the mempool without being mined have expired.
+	// Those which left it before their expiry are assumed to be mined in a
+	// block which has not been scanned yet, and are left out until they are.
+	for _, ts := range cache.seenTSpends() {
+		if !mined[ts.Hash] && !inMempool[ts.Hash] && currentHeight >= ts.Expiry {
+			tspends = append(tspends, ts)
+		}
+	}
+
+	for i := range tspends {
+		if tspends[i].MinedHeight == -1 {
+			tspends[i].setStatus(currentHeight)
+		}
+	}
+	sort.SliceStable(tspends, func(i, j int) bool {
+		a, b := &tspends[i], &tspends[j]
+		if a.InProgress() != b.InProgress() {
+			return a.InProgress()
+		}
+		if a.InProgress() {
+			return a.Expiry < b.Expiry
+		}
+		return a.settledHeight() > b.settledHeight()
+	})
+	return tspends, nil
+}
+
+// minedTSpends returns every tspend mined from the provided height up to the
+// main chain tip at currentHeight, with the final tally of its votes.  Tspends
+// are only mined in treasury vote interval blocks, so only those blocks are
+// scanned, and those which are final are only scanned once.
+func minedTSpends(ctx context.Context, chain chainSource, cache *voteCache,
+	fromHeight, currentHeight int64) ([]TSpend, error) {
+
+	tvi := int64(activeNetParams.TreasuryVoteInterval)
+	first := (fromHeight + tvi - 1) / tvi * tvi
+	scanned, err := cache.tspendScanHeight(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+	start := max(first, scanned+tvi)
+	if start <= currentHeight {
+		log.Printf("Scanning %d treasury vote interval blocks from height %d "+
+			"for tspends", (currentHeight-start)/tvi+1, start)
+	}
+
+	tspends := cache.minedTSpends()
+	for height := start; height <= currentHeight; height += tvi {
+		hash, err := chain.GetBlockHash(ctx, height)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlockHash error: %w", err)
+		}
+		block, err := chain.GetBlock(ctx, hash)
+		if err != nil {
+			return nil, fmt.Errorf("GetBlock error: %w", err)
+		}
+
+		var found []TSpend
+		for _, tx := range block.STransactions {
+			if !stake.IsTSpend(tx) {
+				continue
+			}
+			ts := newTSpend(tx)
+			ts.MinedHeight = height
+			ts.Status = tspendMined
+
+			// The votes which approved the tspend are those cast up
+			// to the block before it was mined.
+			txHash := tx.TxHash()
+			votes, err := chain.GetTreasurySpendVotes(ctx, &block.Header.PrevBlock,
+				[]*chainhash.Hash{&txHash})
+			if err != nil {
+				return nil, fmt.Errorf("GetTreasurySpendVotes error: %w", err)
+			}
+			if len(votes.Votes) != 1 {
+				return nil, fmt.Errorf("GetTreasurySpendVotes returned %d "+
+					"tspends, want 1", len(votes.Votes))
+			}
+			ts.setVotes(&votes.Votes[0])
+			found = append(found, ts)
+		}
+
+		if isFinal(height, currentHeight) {
+			cache.storeMinedTSpends(height, hash.String(), found)
+		}
+		tspends = append(tspends, found...)
+	}
+	return tspends, nil
+}
+
+// mempoolTSpends returns the tspends in the mempool, other than those already
+// mined, with the votes cast on them so far.
+func mempoolTSpends(ctx context.Context, chain chainSource, cache *voteCache,
+	mined map[string]bool) ([]TSpend, error) {
+
+	hashes, err := chain.GetRawMempool(ctx, types.GRMTSpend)
+	if err != nil {
+		return nil, fmt.Errorf("GetRawMempool error: %w", err)
+	}
+	var pending []*chainhash.Hash
+	for _, hash := range hashes {
+		if !mined[hash.String()] {
+			pending = append(pending, hash)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	votes, err := chain.GetTreasurySpendVotes(ctx, nil, pending)
+	if err != nil {
+		return nil, fmt.Errorf("GetTreasurySpendVotes error: %w", err)
+	}
+	tspends := make([]TSpend, 0, len(votes.Votes))
+	for i := range votes.Votes {
+		v := &votes.Votes[i]
+		ts, ok := cache.seenTSpend(v.Hash)
+		if !ok {
+			hash, err := chainhash.NewHashFromStr(v.Hash)
+			if err != nil {
+				return nil, err
+			}
+			tx, err := chain.GetRawTransaction(ctx, hash)
+			if err != nil {
+				return nil, fmt.Errorf("GetRawTransaction error: %w", err)
+			}
+			ts = newTSpend(tx.MsgTx())
+		}
+		ts.setVotes(v)
+		cache.storeSeenTSpend(ts)
+		tspends = append(tspends, ts)
+	}
+	return tspends, nil
+}
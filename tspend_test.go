@@ -0,0 +1,111 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+// tspendByHash returns the tspend with the provided hash served by the API.
+func (h *testHarness) tspendByHash(hash chainhash.Hash) apiTSpend {
+	h.t.Helper()
+	var tspends []apiTSpend
+	h.getJSON("/api/v1/tspends", &tspends)
+	for _, ts := range tspends {
+		if ts.Hash == hash.String() {
+			return ts
+		}
+	}
+	h.t.Fatalf("tspend %v not served", hash)
+	return apiTSpend{}
+}
+
+// TestTreasurySpends ensures that tspends are tracked from the mempool through
+// their voting window until they are mined or expire, that the votes cast on
+// them are tallied against the quorum and approval rules, and that mined
+// tspends are only scanned for once they are final.
+func TestTreasurySpends(t *testing.T) {
+	chain := upgradingChain()
+	h := newTestHarness(t, chain)
+
+	// The voting window of a tspend expiring at 482 is 336-480, in which
+	// 720 votes may be cast, so its quorum is 144 votes.
+	a := chain.addTSpend(newTSpendTx(482, 100e8, 1))
+	b := chain.addTSpend(newTSpendTx(400, 5e8, 2))
+	h.mineTo(301, 12, 11, votes(5, 12, 0))
+
+	ts := h.tspendByHash(a)
+	if ts.Status != tspendPending || ts.VoteStart != 336 || ts.VoteEnd != 480 ||
+		ts.QuorumThreshold != 144 || ts.Amount != 100e8 || ts.MinedHeight != -1 {
+		t.Fatalf("unexpected tspend at 301: %+v", ts)
+	}
+	ts = h.tspendByHash(b)
+	if ts.Status != tspendVoting || ts.Projection == nil || !ts.Projection.CanPass {
+		t.Fatalf("unexpected unvoted tspend at 301: %+v", ts)
+	}
+
+	chain.setTSpendBallot(a, 4, 1)
+	h.mineTo(340, 12, 11, votes(5, 12, 0))
+	ts = h.tspendByHash(a)
+	if ts.Status != tspendVoting || ts.Yes != 20 || ts.No != 5 || ts.QuorumMet {
+		t.Fatalf("unexpected tspend at 340: %+v", ts)
+	}
+	p := ts.Projection
+	if p == nil || p.BlocksRemaining != 139 || p.YesPerBlock != 4 ||
+		p.FinalApproval != 80 || !p.QuorumProjected || !p.Approved {
+		t.Fatalf("unexpected projection at 340: %+v", p)
+	}
+	h.assertPage(`id="tspends"`, `href="/tspends"`, a.String()[:16], "Projected to pass")
+
+	page := h.get("/tspends", http.StatusOK)
+	h.assertContains("/tspends", page, "<title>Treasury Spends - "+dashboardTitle+"</title>",
+		a.String()[:16], b.String()[:16], "100 DCR", "5 DCR", "20.00%", "60.00%")
+
+	// The quorum is met after 36 blocks.
+	h.mineTo(371, 12, 11, votes(5, 12, 0))
+	if ts = h.tspendByHash(a); ts.Status != tspendApproved || !ts.QuorumMet ||
+		ts.ApprovalRating != 80 || ts.Projection != nil {
+		t.Fatalf("unexpected tspend at 371: %+v", ts)
+	}
+
+	// The tspend is mined in the next treasury vote interval block, with
+	// the votes cast before it.
+	chain.approveTSpend(a)
+	h.mineTo(400, 12, 11, votes(5, 12, 0))
+	if ts = h.tspendByHash(a); ts.Status != tspendMined || ts.MinedHeight != 384 ||
+		ts.Yes != 192 || ts.No != 48 {
+		t.Fatalf("unexpected mined tspend: %+v", ts)
+	}
+	if ts = h.tspendByHash(b); ts.Status != tspendExpired || ts.Yes != 0 {
+		t.Fatalf("unexpected expired tspend: %+v", ts)
+	}
+	tspends := templateInformation.Load().TSpends
+	if len(tspends) != 2 || tspends[0].Hash != b.String() {
+		t.Fatalf("tspends not ordered most recently settled first: %+v", tspends)
+	}
+	if page := h.get("/", http.StatusOK); strings.Contains(string(page), `id="tspends"`) {
+		t.Error("home page lists tspends which are no longer in progress")
+	}
+
+	// Blocks which are not treasury vote interval blocks are not scanned,
+	// and the final scan of 384 is not repeated.
+	scans := len(h.dcrd.requestsOf("getblock"))
+	h.mineTo(401, 12, 11, votes(5, 12, 0))
+	if n := len(h.dcrd.requestsOf("getblock")); n != scans {
+		t.Errorf("%d blocks scanned for tspends below the next interval", n-scans)
+	}
+
+	// A reorganization disconnecting the block the tspend was mined in
+	// returns it to the mempool.
+	h.reorg(380)
+	if ts = h.tspendByHash(a); ts.Status != tspendApproved || ts.MinedHeight != -1 ||
+		ts.Yes != 180 {
+		t.Fatalf("unexpected tspend after reorg: %+v", ts)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+)
+
+// updater keeps templateInformation current by recomputing it when block
+// notifications are received from dcrd, using the connection maintained by
+// a dcrdBackend.  Notifications which are queued while
+// an update is in progress are coalesced, so that the next update is computed
+// only for the latest tip.  It tracks the main chain tip in order to detect
+// chain reorganizations.
+type updater struct {
+	chain chainSource
+	cache *voteCache
+	ntfns *notificationQueue
+
+	// tipHash is the hash of the main chain tip, according to the most
+	// recent notification.
+	tipHash chainhash.Hash
+	// reorging is set while blocks are being disconnected, so that a single
+	// reorganization disconnecting multiple blocks is only counted once.
+	reorging bool
+	// reorgs is the number of chain reorganizations seen since startup.
+	reorgs uint64
+	// skipped is the number of block notifications which did not result in
+	// an update because a later notification was queued.
+	skipped uint64
+	// catalogReloads is the number of agenda catalogs applied.
+	catalogReloads uint64
+
+	// onUpdate, if set, is called with the height of each block after the
+	// voting information has been updated for it.
+	onUpdate func(height int64)
+	// onPublish, if set, is called each time new voting information is
+	// published to templateInformation.
+	onPublish func()
+
+	// catalogs receives reloaded agenda catalogs.  They are applied by the
+	// same goroutine as updates, so that the voting information is only
+	// published by one goroutine, and an update which is in progress does
+	// not replace the new metadata with the old.  It holds the newest
+	// catalog which has not been applied yet, and is sent to by
+	// reloadCatalog.
+	catalogs chan *agendaCatalog
+}
+
+// bestBlockHeader returns the header of the current main chain tip.
+func bestBlockHeader(ctx context.Context, chain chainSource) (*wire.BlockHeader, error) {
+	// Get the current best block (height and hash)
+	hash, err := chain.GetBestBlockHash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Request the current block header
+	return chain.GetBlockHeader(ctx, hash)
+}
+
+// update recomputes the voting information for the provided block and
+// publishes it. If the update fails the previous voting information continues
+// to be served.
+func (u *updater) update(ctx context.Context, header *wire.BlockHeader) {
+	u.tipHash = header.BlockHash()
+
+	start := time.Now()
+	t, err := buildTemplateInformation(ctx, u.chain, u.cache,
+		templateInformation.Load(), header)
+	serviceMetrics.updateDone(time.Since(start), err)
+	if err != nil {
+		log.Printf("Failed to update vote information for block %v (height %d): %v",
+			u.tipHash, header.Height, err)
+	} else {
+		t.Reorgs = u.reorgs
+		t.SkippedUpdates = u.skipped
+		t.CatalogReloads = u.catalogReloads
+		t.BackendAvailable = true
+		t.BackendError = ""
+		t.LastUpdate = time.Now()
+		templateInformation.Store(t)
+		u.published()
+	}
+	if err := u.cache.save(); err != nil {
+		log.Printf("Failed to save vote cache: %v", err)
+	}
+	if u.onUpdate != nil {
+		u.onUpdate(int64(header.Height))
+	}
+}
+
+// setUnavailable publishes the current voting information marked as stale
+// because dcrd can not be reached.
+func (u *updater) setUnavailable(reason error) {
+	t := *templateInformation.Load()
+	t.BackendAvailable = false
+	t.BackendError = reason.Error()
+	templateInformation.Store(&t)
+	u.published()
+}
+
+// applyCatalog replaces the agenda catalog, and publishes the current voting
+// information with the new metadata.
+func (u *updater) applyCatalog(catalog *agendaCatalog) {
+	currentAgendaCatalog.Store(catalog)
+	u.catalogReloads++
+	prev := templateInformation.Load()
+	t := *prev
+	t.CatalogReloads = u.catalogReloads
+	t.Agendas = slices.Clone(prev.Agendas)
+	for i := range t.Agendas {
+		catalog.apply(&t.Agendas[i])
+	}
+	templateInformation.Store(&t)
+	u.published()
+	log.Println("Agenda catalog reloaded.")
+}
+
+func (u *updater) published() {
+	if u.onPublish != nil {
+		u.onPublish()
+	}
+}
+
+// catchUp updates the voting information for the current main chain tip after
+// connecting to dcrd.  Any notifications queued before then are superseded by
+// the update.
+func (u *updater) catchUp(ctx context.Context) error {
+	u.skipped += uint64(len(u.ntfns.popAll()))
+	u.reorging = false
+
+	header, err := bestBlockHeader(ctx, u.chain)
+	if err != nil {
+		return err
+	}
+	log.Printf("Catching up to block %v (height %d)", header.BlockHash(),
+		header.Height)
+	dcrdTipHeight.Store(int64(header.Height))
+	u.update(ctx, header)
+	return nil
+}
+
+// reorg records a chain reorganization which orphaned the blocks from height
+// onwards, and discards any cached data derived from them.
+func (u *updater) reorg(height int64) {
+	u.reorging = true
+	u.reorgs++
+	log.Printf("Chain reorganization #%d detected, blocks from height %d "+
+		"orphaned", u.reorgs, height)
+	u.cache.invalidateFrom(height)
+}
+
+// blockConnected processes a block connected notification.
+func (u *updater) blockConnected(header *wire.BlockHeader) {
+	log.Printf("Block %v (height %v) connected", header.BlockHash(), header.Height)
+
+	// The block may already have been included in a catch up update.
+	if header.BlockHash() == u.tipHash {
+		return
+	}
+
+	// A block which does not extend the tip means disconnected notifications
+	// were missed, so the height the chains forked at is unknown. Cached
+	// data is keyed by block hash, so anything which was orphaned will be
+	// detected and discarded when it is next used.
+	if !u.reorging && header.PrevBlock != u.tipHash {
+		u.reorg(int64(header.Height))
+	}
+	u.reorging = false
+	u.tipHash = header.BlockHash()
+}
+
+// blockDisconnected processes a block disconnected notification. The block's
+// parent is the new main chain tip.
+func (u *updater) blockDisconnected(header *wire.BlockHeader) {
+	log.Printf("Block %v (height %v) disconnected", header.BlockHash(), header.Height)
+
+	if !u.reorging {
+		u.reorg(int64(header.Height))
+	} else {
+		u.cache.invalidateFrom(int64(header.Height))
+	}
+	u.tipHash = header.PrevBlock
+}
+
+// processNotifications processes every queued block notification, and then
+// updates the voting information once for the resulting tip.
+func (u *updater) processNotifications(ctx context.Context) {
+	ntfns := u.ntfns.popAll()
+	if len(ntfns) == 0 {
+		return
+	}
+
+	for i := range ntfns {
+		if ntfns[i].connected {
+			u.blockConnected(&ntfns[i].header)
+		} else {
+			u.blockDisconnected(&ntfns[i].header)
+		}
+	}
+	if len(ntfns) > 1 {
+		u.skipped += uint64(len(ntfns) - 1)
+		log.Printf("Coalesced %d block notifications into one update "+
+			"(%d skipped since startup)", len(ntfns), u.skipped)
+	}
+
+	// The header of the tip is already known unless the final notification
+	// disconnected a block.
+	last := &ntfns[len(ntfns)-1]
+	header := &last.header
+	if !last.connected {
+		var err error
+		header, err = u.chain.GetBlockHeader(ctx, &u.tipHash)
+		if err != nil {
+			log.Printf("Failed to get header of block %v: %v", u.tipHash, err)
+			return
+		}
+	}
+	u.update(ctx, header)
+}
+
+// reloadCatalog passes a reloaded agenda catalog to the goroutine applying
+// them, replacing any catalog it has not applied yet.  It does not wait for the
+// catalog to be applied, which may take until an update or a delay before
+// reconnecting to dcrd has finished.
+func (u *updater) reloadCatalog(catalog *agendaCatalog) {
+	for {
+		select {
+		case u.catalogs <- catalog:
+			return
+		default:
+		}
+
+		// Discard the pending catalog, unless it has been received in
+		// the meantime, and try again.
+		select {
+		case <-u.catalogs:
+		default:
+		}
+	}
+}
+
+// run processes block notifications and reloaded agenda catalogs until the
+// context is canceled or disconnected is closed.
+func (u *updater) run(ctx context.Context, disconnected <-chan struct{}) {
+	for {
+		select {
+		case <-u.ntfns.ready:
+			u.processNotifications(ctx)
+		case catalog := <-u.catalogs:
+			u.applyCatalog(catalog)
+		case <-disconnected:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+)
+
+// TestReorg ensures that the voting information is recomputed from the new
+// best chain after a chain reorganization, and that the reorganization is
+// counted.
+func TestReorg(t *testing.T) {
+	ballot := concatVotes(votes(3, 12, bitsYes), votes(1, 12, bitsNo),
+		votes(1, 12, bitsAbstain))
+	chain := upgradingChain()
+	chain.mineTo(367, 12, 11, votes(5, 12, 0))
+	chain.mineTo(500, 12, 12, ballot)
+	chain.setStatus(12, "maxtreasuryspend", "started")
+	h := newTestHarness(t, chain)
+
+	// Blocks 464-482 of the voting window remain in the main chain, and are
+	// followed by 23 blocks which each include 5 no votes.
+	h.reorg(482)
+	if height := templateInformation.Load().BlockHeight; height != 482 {
+		t.Fatalf("block height %d after disconnecting blocks, want 482", height)
+	}
+	h.mineTo(505, 12, 12, votes(5, 12, bitsNo))
+
+	a := h.agenda("maxtreasuryspend")
+	if a.VoteCounts["yes"] != 57 || a.VoteCounts["no"] != 134 || a.VoteCounts["abstain"] != 19 {
+		t.Errorf("vote counts %v, want yes 57 no 134 abstain 19", a.VoteCounts)
+	}
+
+	var status apiStatus
+	h.getJSON("/api/v1/status", &status)
+	if status.BlockHeight != 505 || status.Reorgs != 1 {
+		t.Errorf("API height %d reorgs %d, want 505 1", status.BlockHeight, status.Reorgs)
+	}
+}
+
+// TestReorgInvalidatesCache ensures that cached data derived from orphaned
+// blocks is discarded.
+func TestReorgInvalidatesCache(t *testing.T) {
+	chain := lockedInChain()
+	h := newTestHarness(t, chain)
+
+	// The voting window ending at 783 and the intervals up to 815 are
+	// final at height 900.  Orphan everything from height 700 onwards and
+	// replace it with blocks voting no.
+	h.reorg(699)
+	h.mineTo(910, 12, 12, votes(5, 12, bitsNo))
+
+	a := h.agenda("maxtreasuryspend")
+	if a.VoteCounts["yes"] != 708 || a.VoteCounts["no"] != 656 || a.VoteCounts["abstain"] != 236 {
+		t.Errorf("vote counts %v, want yes 708 no 656 abstain 236", a.VoteCounts)
+	}
+	if reorgs := templateInformation.Load().Reorgs; reorgs != 1 {
+		t.Errorf("%d reorgs, want 1", reorgs)
+	}
+}
+
+// TestCoalescedUpdates ensures that notifications for blocks which arrive
+// while an update is in progress are coalesced into a single update for the
+// latest tip.
+func TestCoalescedUpdates(t *testing.T) {
+	h := newTestHarness(t, upgradingChain())
+
+	// Notifications for all 300 blocks are sent at once, far faster than
+	// the voting information can be computed for each of them.
+	h.mineTo(600, 12, 11, votes(5, 12, 0))
+
+	ti := templateInformation.Load()
+	if ti.BlockHeight != 600 {
+		t.Fatalf("block height %d, want 600", ti.BlockHeight)
+	}
+	if ti.SkippedUpdates == 0 {
+		t.Fatalf("no updates were skipped while processing 300 blocks")
+	}
+
+	var status apiStatus
+	h.getJSON("/api/v1/status", &status)
+	if status.SkippedUpdates != ti.SkippedUpdates {
+		t.Errorf("API skipped updates %d, want %d", status.SkippedUpdates,
+			ti.SkippedUpdates)
+	}
+}
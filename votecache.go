@@ -0,0 +1,490 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/decred/dcrd/rpc/jsonrpc/types/v4"
+)
+
+// voteCacheVersion is the version of the on-disk cache format.  Cache files
+// written with a different version are discarded and rebuilt from dcrd.
+// Fields added without changing the version are empty when older files are
+// loaded, so the data missing them must be treated as not cached, as tally does
+// for incomplete vote progressions.  Otherwise the version must be changed.
+const voteCacheVersion = 1
+
+// voteCacheData is the content of a vote cache file.
+type voteCacheData struct {
+	Version int    `json:"version"`
+	Network string `json:"network"`
+	// Intervals are complete stake version intervals, ordered oldest to
+	// newest, starting with the first interval.
+	Intervals []types.VersionInterval `json:"intervals"`
+	// IntervalsHash is the hash of the final block of the newest cached
+	// interval.  It is used to detect the cached intervals being orphaned.
+	IntervalsHash string `json:"intervals_hash"`
+	// Tallies are the vote counts of agendas whose voting window has ended,
+	// keyed by tallyKey.
+	Tallies map[string]cachedTally `json:"tallies"`
+	// BlockVersions are the block versions found in the PoW rolling windows
+	// ending at stake version interval boundaries, keyed by the hash of the
+	// final block of the window.
+	BlockVersions map[string]cachedBlockVersions `json:"block_versions"`
+	// TSpendScanHeight and TSpendScanHash are the height and hash of the
+	// newest treasury vote interval block which has been scanned for
+	// tspends.  Every such block from the treasury activation up to it has
+	// been scanned.
+	TSpendScanHeight int64  `json:"tspend_scan_height"`
+	TSpendScanHash   string `json:"tspend_scan_hash"`
+	// MinedTSpends are the tspends found in the scanned blocks, and
+	// SeenTSpends those seen in the mempool, keyed by hash.
+	MinedTSpends map[string]cachedTSpend `json:"mined_tspends"`
+	SeenTSpends  map[string]cachedTSpend `json:"seen_tspends"`
+}
+
+// cachedTally is the vote counts of an agenda whose voting window has ended.
+type cachedTally struct {
+	// EndHeight is the height of the final block of the voting window.
+	EndHeight   int64            `json:"end_height"`
+	Counts      map[string]int64 `json:"counts"`
+	Progression VoteProgression  `json:"progression"`
+}
+
+// cachedBlockVersions is the number of blocks of each block version in a PoW
+// rolling window.
+type cachedBlockVersions struct {
+	// Height is the height of the final block of the window.
+	Height int64           `json:"height"`
+	Counts map[int32]int64 `json:"counts"`
+}
+
+// cachedTSpend is a tspend with the votes cast on it.  The votes of mined
+// tspends are final, while those of tspends seen in the mempool are as of when
+// they were last seen.
+type cachedTSpend struct {
+	Amount      int64 `json:"amount"`
+	Expiry      int64 `json:"expiry"`
+	VoteStart   int64 `json:"vote_start"`
+	VoteEnd     int64 `json:"vote_end"`
+	Yes         int64 `json:"yes"`
+	No          int64 `json:"no"`
+	MinedHeight int64 `json:"mined_height"`
+}
+
+// voteCache is a persistent store of voting data which can no longer change
+// because the blocks it was derived from are buried deep in the main chain.
+// It allows finished stake version intervals, agenda tallies, PoW rolling
+// windows and mined tspends to be reused
+// across blocks and restarts rather than being requested from dcrd again.
+// Tspends seen in the mempool are also remembered, so that those which expire
+// without being mined are still known.
+//
+// All methods may be called on a nil *voteCache, in which case nothing is
+// cached.  A voteCache is not safe for concurrent use.
+type voteCache struct {
+	path  string
+	data  voteCacheData
+	dirty bool
+}
+
+// newVoteCache returns a vote cache persisted at path, loading any data
+// previously saved there for the active network.
+func newVoteCache(path string) (*voteCache, error) {
+	c := &voteCache{
+		path: path,
+		data: voteCacheData{
+			Version:       voteCacheVersion,
+			Network:       activeNetParams.Name,
+			Tallies:       make(map[string]cachedTally),
+			BlockVersions: make(map[string]cachedBlockVersions),
+			MinedTSpends:  make(map[string]cachedTSpend),
+			SeenTSpends:   make(map[string]cachedTSpend),
+		},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vote cache: %w", err)
+	}
+
+	var data voteCacheData
+	if err := json.Unmarshal(b, &data); err != nil {
+		log.Printf("Discarding unreadable vote cache %s: %v", path, err)
+		return c, nil
+	}
+	if data.Version != voteCacheVersion || data.Network != activeNetParams.Name {
+		log.Printf("Discarding vote cache %s for network %q version %d",
+			path, data.Network, data.Version)
+		return c, nil
+	}
+	if data.Tallies == nil {
+		data.Tallies = make(map[string]cachedTally)
+	}
+	if data.BlockVersions == nil {
+		data.BlockVersions = make(map[string]cachedBlockVersions)
+	}
+	if data.MinedTSpends == nil {
+		data.MinedTSpends = make(map[string]cachedTSpend)
+	}
+	if data.SeenTSpends == nil {
+		data.SeenTSpends = make(map[string]cachedTSpend)
+	}
+	c.data = data
+
+	log.Printf("Loaded %d stake version intervals, %d agenda tallies, %d "+
+		"PoW rolling windows and %d tspends from %s", len(data.Intervals),
+		len(data.Tallies), len(data.BlockVersions),
+		len(data.MinedTSpends)+len(data.SeenTSpends), path)
+
+	return c, nil
+}
+
+// isFinal returns whether the block at the provided height is buried deeply
+// enough below the tip at currentHeight for it to be treated as final.
+func isFinal(height, currentHeight int64) bool {
+	return currentHeight-height >= int64(activeNetParams.CoinbaseMaturity)
+}
+
+// intervals returns the cached stake version intervals, ordered oldest to
+// newest.  Cached intervals which are no longer part of the main chain are
+// discarded.
+func (c *voteCache) intervals(ctx context.Context, chain chainSource) ([]types.VersionInterval, error) {
+	if c == nil || len(c.data.Intervals) == 0 {
+		return nil, nil
+	}
+
+	last := c.data.Intervals[len(c.data.Intervals)-1]
+	hash, err := chain.GetBlockHash(ctx, last.EndHeight-1)
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockHash error: %w", err)
+	}
+	if hash.String() != c.data.IntervalsHash {
+		log.Printf("Cached stake version intervals are no longer in the "+
+			"main chain (block %d is %v, want %v)", last.EndHeight-1, hash,
+			c.data.IntervalsHash)
+		c.resetIntervals()
+		return nil, nil
+	}
+
+	return append([]types.VersionInterval(nil), c.data.Intervals...), nil
+}
+
+// resetIntervals discards every cached stake version interval.
+func (c *voteCache) resetIntervals() {
+	if c == nil || len(c.data.Intervals) == 0 {
+		return
+	}
+	c.data.Intervals = nil
+	c.data.IntervalsHash = ""
+	c.dirty = true
+}
+
+// storeIntervals caches every complete and final interval of svis, which must
+// be every stake version interval up to currentHeight ordered oldest to newest.
+func (c *voteCache) storeIntervals(ctx context.Context, chain chainSource,
+	svis []types.VersionInterval, currentHeight int64) error {
+
+	if c == nil {
+		return nil
+	}
+
+	n := len(c.data.Intervals)
+	for n < len(svis) {
+		svi := svis[n]
+		if svi.EndHeight-svi.StartHeight < activeNetParams.StakeVersionInterval ||
+			!isFinal(svi.EndHeight-1, currentHeight) {
+			break
+		}
+		n++
+	}
+	if n == len(c.data.Intervals) {
+		return nil
+	}
+
+	hash, err := chain.GetBlockHash(ctx, svis[n-1].EndHeight-1)
+	if err != nil {
+		return fmt.Errorf("GetBlockHash error: %w", err)
+	}
+	c.data.Intervals = append([]types.VersionInterval(nil), svis[:n]...)
+	c.data.IntervalsHash = hash.String()
+	c.dirty = true
+	return nil
+}
+
+// tallyKey returns the key of the vote counts of an agenda whose voting window
+// ends with the block with the provided hash.
+func tallyKey(agendaID string, voteVersion uint32, endHash string) string {
+	return fmt.Sprintf("%s/%d/%s", agendaID, voteVersion, endHash)
+}
+
+// tally returns the cached vote counts and their progression of an agenda
+// whose voting window ends with the block with the provided hash.  Tallies
+// cached without a complete progression are treated as not being cached, so
+// that the votes are counted again.
+func (c *voteCache) tally(agendaID string, voteVersion uint32, endHash string) (cachedTally, bool) {
+	if c == nil {
+		return cachedTally{}, false
+	}
+	tally, ok := c.data.Tallies[tallyKey(agendaID, voteVersion, endHash)]
+	if !ok || !tally.Progression.complete() {
+		return cachedTally{}, false
+	}
+	return tally, true
+}
+
+// storeTally caches the vote counts and their progression of an agenda whose
+// voting window ends with the block with the provided hash and height.
+func (c *voteCache) storeTally(agendaID string, voteVersion uint32, endHash string,
+	endHeight int64, counts map[string]int64, progression VoteProgression) {
+
+	if c == nil {
+		return
+	}
+	stored := make(map[string]int64, len(counts))
+	for id, n := range counts {
+		stored[id] = n
+	}
+	c.data.Tallies[tallyKey(agendaID, voteVersion, endHash)] = cachedTally{
+		EndHeight:   endHeight,
+		Counts:      stored,
+		Progression: progression,
+	}
+	c.dirty = true
+}
+
+// blockVersions returns the cached block version counts of the PoW rolling
+// window ending with the block with the provided hash.
+func (c *voteCache) blockVersions(endHash string) (map[int32]int64, bool) {
+	if c == nil {
+		return nil, false
+	}
+	window, ok := c.data.BlockVersions[endHash]
+	return window.Counts, ok
+}
+
+// storeBlockVersions caches the block version counts of the PoW rolling window
+// ending with the block with the provided hash and height.
+func (c *voteCache) storeBlockVersions(endHash string, height int64, counts map[int32]int64) {
+	if c == nil {
+		return
+	}
+	stored := make(map[int32]int64, len(counts))
+	for v, n := range counts {
+		stored[v] = n
+	}
+	c.data.BlockVersions[endHash] = cachedBlockVersions{
+		Height: height,
+		Counts: stored,
+	}
+	c.dirty = true
+}
+
+// tspendScanHeight returns the height of the newest treasury vote interval
+// block which has been scanned for tspends, or zero if none have been.  If it
+// is no longer part of the main chain, every scanned block and the tspends
+// found in them are discarded.
+func (c *voteCache) tspendScanHeight(ctx context.Context, chain chainSource) (int64, error) {
+	if c == nil || c.data.TSpendScanHash == "" {
+		return 0, nil
+	}
+
+	hash, err := chain.GetBlockHash(ctx, c.data.TSpendScanHeight)
+	if err != nil {
+		return 0, fmt.Errorf("GetBlockHash error: %w", err)
+	}
+	if hash.String() != c.data.TSpendScanHash {
+		log.Printf("Scanned treasury vote interval blocks are no longer in "+
+			"the main chain (block %d is %v, want %v)", c.data.TSpendScanHeight,
+			hash, c.data.TSpendScanHash)
+		c.resetTSpendScan()
+		return 0, nil
+	}
+	return c.data.TSpendScanHeight, nil
+}
+
+// resetTSpendScan discards every scanned block and the tspends found in them.
+func (c *voteCache) resetTSpendScan() {
+	if c == nil || c.data.TSpendScanHash == "" {
+		return
+	}
+	c.data.TSpendScanHeight = 0
+	c.data.TSpendScanHash = ""
+	clear(c.data.MinedTSpends)
+	c.dirty = true
+}
+
+// storeMinedTSpends records the treasury vote interval block with the provided
+// height and hash, which must follow the previously scanned block, as scanned,
+// and caches the tspends mined in it.
+func (c *voteCache) storeMinedTSpends(height int64, hash string, tspends []TSpend) {
+	if c == nil {
+		return
+	}
+	c.data.TSpendScanHeight = height
+	c.data.TSpendScanHash = hash
+	for _, ts := range tspends {
+		c.data.MinedTSpends[ts.Hash] = newCachedTSpend(&ts)
+		delete(c.data.SeenTSpends, ts.Hash)
+	}
+	c.dirty = true
+}
+
+// minedTSpends returns the cached tspends mined in the scanned blocks.
+func (c *voteCache) minedTSpends() []TSpend {
+	if c == nil {
+		return nil
+	}
+	tspends := make([]TSpend, 0, len(c.data.MinedTSpends))
+	for hash, cached := range c.data.MinedTSpends {
+		ts := cached.tspend(hash)
+		ts.Status = tspendMined
+		tspends = append(tspends, ts)
+	}
+	return tspends
+}
+
+// seenTSpend returns the tspend with the provided hash if it has been seen in
+// the mempool.
+func (c *voteCache) seenTSpend(hash string) (TSpend, bool) {
+	if c == nil {
+		return TSpend{}, false
+	}
+	cached, ok := c.data.SeenTSpends[hash]
+	return cached.tspend(hash), ok
+}
+
+// seenTSpends returns every tspend which has been seen in the mempool and has
+// not been found in a scanned block.
+func (c *voteCache) seenTSpends() []TSpend {
+	if c == nil {
+		return nil
+	}
+	tspends := make([]TSpend, 0, len(c.data.SeenTSpends))
+	for hash, cached := range c.data.SeenTSpends {
+		tspends = append(tspends, cached.tspend(hash))
+	}
+	return tspends
+}
+
+// storeSeenTSpend caches a tspend seen in the mempool, with the votes cast on
+// it so far, so that it is still known once it has expired.
+func (c *voteCache) storeSeenTSpend(ts TSpend) {
+	if c == nil {
+		return
+	}
+	cached := newCachedTSpend(&ts)
+	if prev, ok := c.data.SeenTSpends[ts.Hash]; ok && prev == cached {
+		return
+	}
+	c.data.SeenTSpends[ts.Hash] = cached
+	c.dirty = true
+}
+
+func newCachedTSpend(ts *TSpend) cachedTSpend {
+	return cachedTSpend{
+		Amount:      ts.Amount,
+		Expiry:      ts.Expiry,
+		VoteStart:   ts.VoteStart,
+		VoteEnd:     ts.VoteEnd,
+		Yes:         ts.Yes,
+		No:          ts.No,
+		MinedHeight: ts.MinedHeight,
+	}
+}
+
+func (c *cachedTSpend) tspend(hash string) TSpend {
+	return TSpend{
+		Hash:        hash,
+		Amount:      c.Amount,
+		Expiry:      c.Expiry,
+		VoteStart:   c.VoteStart,
+		VoteEnd:     c.VoteEnd,
+		Yes:         c.Yes,
+		No:          c.No,
+		MinedHeight: c.MinedHeight,
+	}
+}
+
+// invalidateFrom discards all cached data derived from blocks at or above the
+// provided height, which have been orphaned by a chain reorganization.
+func (c *voteCache) invalidateFrom(height int64) {
+	if c == nil {
+		return
+	}
+
+	// Only the hash of the newest cached interval is known, so every
+	// interval is discarded when any of them have been orphaned.  This is
+	// rare since cached intervals are buried deep in the main chain.
+	if n := len(c.data.Intervals); n > 0 && c.data.Intervals[n-1].EndHeight-1 >= height {
+		log.Printf("Discarding cached stake version intervals orphaned at "+
+			"height %d", height)
+		c.resetIntervals()
+	}
+
+	for key, tally := range c.data.Tallies {
+		if tally.EndHeight >= height {
+			log.Printf("Discarding cached agenda tally %s orphaned at "+
+				"height %d", key, height)
+			delete(c.data.Tallies, key)
+			c.dirty = true
+		}
+	}
+
+	// The same applies to the scanned treasury vote interval blocks.
+	if c.data.TSpendScanHash != "" && c.data.TSpendScanHeight >= height {
+		log.Printf("Discarding scanned treasury vote interval blocks "+
+			"orphaned at height %d", height)
+		c.resetTSpendScan()
+	}
+
+	for hash, window := range c.data.BlockVersions {
+		if window.Height >= height {
+			log.Printf("Discarding cached PoW rolling window ending with "+
+				"block %s orphaned at height %d", hash, height)
+			delete(c.data.BlockVersions, hash)
+			c.dirty = true
+		}
+	}
+}
+
+// save writes the cache to disk if it has been modified since it was last
+// saved.
+func (c *voteCache) save() error {
+	if c == nil || !c.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(&c.data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a crash can not leave a
+	// partially written cache behind.
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
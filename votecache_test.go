@@ -0,0 +1,212 @@
+// Copyright (c) 2017-2025 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// lockedInChain returns a chain at height 900, after the voting window of the
+// maxtreasuryspend agenda described by TestVotingLifecycle has ended.
+func lockedInChain() *fakeChain {
+	ballot := concatVotes(votes(3, 12, bitsYes), votes(1, 12, bitsNo),
+		votes(1, 12, bitsAbstain))
+	chain := upgradingChain()
+	chain.mineTo(367, 12, 11, votes(5, 12, 0))
+	chain.mineTo(900, 12, 12, ballot)
+	chain.setStatus(12, "maxtreasuryspend", "lockedin")
+	return chain
+}
+
+// stakeVersionInfoCounts returns the count parameter of every
+// getstakeversioninfo request received by the fake dcrd.
+func stakeVersionInfoCounts(t *testing.T, d *fakeDcrd) []int32 {
+	t.Helper()
+	var counts []int32
+	for _, req := range d.requestsOf("getstakeversioninfo") {
+		var count int32
+		if err := param(req, 0, &count); err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, count)
+	}
+	return counts
+}
+
+// assertLockedInTally asserts the final tally of the lockedInChain vote.
+func assertLockedInTally(h *testHarness) {
+	h.t.Helper()
+	a := h.agenda("maxtreasuryspend")
+	if a.VoteCounts["yes"] != 960 || a.VoteCounts["no"] != 320 || a.VoteCounts["abstain"] != 320 {
+		h.t.Errorf("vote counts %v, want yes 960 no 320 abstain 320", a.VoteCounts)
+	}
+}
+
+// TestVoteCacheReuse ensures that finished stake version intervals, agenda
+// tallies and PoW rolling windows are persisted and reused after a restart.
+func TestVoteCacheReuse(t *testing.T) {
+	chain := lockedInChain()
+	cachePath := filepath.Join(t.TempDir(), voteCacheFilename)
+
+	// Every interval, the tally of the finished voting window and the PoW
+	// rolling window at the end of the upgrade interval are requested on
+	// the first run.  At height 900 there are 7 intervals, of which the 6
+	// complete intervals are final.
+	h := newTestHarnessWithCache(t, chain, cachePath)
+	assertLockedInTally(h)
+	if counts := stakeVersionInfoCounts(t, h.dcrd); len(counts) != 2 || counts[1] != 7 {
+		t.Fatalf("getstakeversioninfo counts %v, want [4 7]", counts)
+	}
+	if n := len(h.dcrd.requestsOf("getstakeversions")); n != 4 {
+		t.Fatalf("%d getstakeversions requests, want 4", n)
+	}
+
+	// After a restart only the current interval is requested and the votes
+	// are not counted again.
+	h = newTestHarnessWithCache(t, chain, cachePath)
+	assertLockedInTally(h)
+	if counts := stakeVersionInfoCounts(t, h.dcrd); len(counts) != 2 || counts[1] != 1 {
+		t.Fatalf("getstakeversioninfo counts %v, want [4 1]", counts)
+	}
+	if n := len(h.dcrd.requestsOf("getstakeversions")); n != 2 {
+		t.Fatalf("%d getstakeversions requests, want 2", n)
+	}
+	if ti := templateInformation.Load(); !ti.PosUpgrade.Completed ||
+		ti.PosUpgrade.UpgradeInterval.StartHeight != 256 {
+		t.Errorf("PoS upgrade completed %v in interval starting %d, want true 256",
+			ti.PosUpgrade.Completed, ti.PosUpgrade.UpgradeInterval.StartHeight)
+	}
+}
+
+// TestVoteCacheOrphaned ensures that cached intervals which are no longer part
+// of the main chain are discarded.
+func TestVoteCacheOrphaned(t *testing.T) {
+	chain := lockedInChain()
+	cachePath := filepath.Join(t.TempDir(), voteCacheFilename)
+	newTestHarnessWithCache(t, chain, cachePath)
+
+	// Replace the hash of the final cached block, as though it had been
+	// reorganized out of the main chain.
+	b, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data voteCacheData
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	data.IntervalsHash = chain.params.GenesisHash.String()
+	b, err = json.Marshal(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHarnessWithCache(t, chain, cachePath)
+	assertLockedInTally(h)
+	if counts := stakeVersionInfoCounts(t, h.dcrd); len(counts) != 2 || counts[1] != 7 {
+		t.Fatalf("getstakeversioninfo counts %v, want [4 7]", counts)
+	}
+}
+
+// TestVoteCacheOldVersion ensures that a cache file written with a different
+// version is rebuilt rather than being used or causing an error.
+func TestVoteCacheOldVersion(t *testing.T) {
+	chain := lockedInChain()
+	cachePath := filepath.Join(t.TempDir(), voteCacheFilename)
+	newTestHarnessWithCache(t, chain, cachePath)
+
+	// Rewrite the cache as an older version with incorrect tallies, which
+	// must not be used.
+	b, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data voteCacheData
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	data.Version = voteCacheVersion - 1
+	for key, tally := range data.Tallies {
+		tally.Counts = map[string]int64{"yes": 1}
+		data.Tallies[key] = tally
+	}
+	b, err = json.Marshal(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHarnessWithCache(t, chain, cachePath)
+	assertLockedInTally(h)
+	if counts := stakeVersionInfoCounts(t, h.dcrd); len(counts) != 2 || counts[1] != 7 {
+		t.Fatalf("getstakeversioninfo counts %v, want [4 7]", counts)
+	}
+
+	b, err = os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = voteCacheData{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Version != voteCacheVersion || len(data.Intervals) != 6 {
+		t.Errorf("rebuilt cache version %d with %d intervals, want %d with 6",
+			data.Version, len(data.Intervals), voteCacheVersion)
+	}
+}
+
+// TestVoteCacheIncompleteProgression ensures that agenda tallies cached
+// without the invalid and other version vote counts of their progression, as
+// written before those were recorded, are counted again rather than used.
+func TestVoteCacheIncompleteProgression(t *testing.T) {
+	chain := lockedInChain()
+	cachePath := filepath.Join(t.TempDir(), voteCacheFilename)
+	newTestHarnessWithCache(t, chain, cachePath)
+
+	b, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data voteCacheData
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Tallies) == 0 {
+		t.Fatal("no cached tallies")
+	}
+	for key, tally := range data.Tallies {
+		tally.Progression.Invalid = nil
+		tally.Progression.OtherVersion = nil
+		data.Tallies[key] = tally
+	}
+	b, err = json.Marshal(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHarnessWithCache(t, chain, cachePath)
+	assertLockedInTally(h)
+	a := h.agenda("maxtreasuryspend")
+	if n := len(a.Progression.Heights); n == 0 || len(a.Progression.Invalid) != n ||
+		len(a.Progression.OtherVersion) != n {
+		t.Errorf("progression of %d heights with %d invalid and %d other "+
+			"version counts", n, len(a.Progression.Invalid),
+			len(a.Progression.OtherVersion))
+	}
+	h.get("/agenda/maxtreasuryspend", http.StatusOK)
+}
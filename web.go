@@ -3,12 +3,15 @@ package main
 import (
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
 	"math"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/dustin/go-humanize/english"
@@ -18,9 +21,11 @@ var funcMap = template.FuncMap{
 	"plus":                 plus,
 	"minus":                minus,
 	"minus64":              minus64,
+	"plus64":               plus64,
 	"commaSeparate":        commaSeparate,
 	"twoDecimalPlaces":     twoDecimalPlaces,
 	"blocksToTimeEstimate": blocksToTimeEstimate,
+	"timeSince":            timeSince,
 }
 
 func plus(a, b int) int {
@@ -32,6 +37,9 @@ func minus(a, b int) int {
 func minus64(a, b int64) int64 {
 	return a - b
 }
+func plus64(a, b int64) int64 {
+	return a + b
+}
 func commaSeparate(number int64) string {
 	return humanize.Comma(number)
 }
@@ -39,46 +47,119 @@ func twoDecimalPlaces(number float64) string {
 	number = math.Floor(number*100) / 100
 	return fmt.Sprintf("%.2f", number)
 }
+func timeSince(t time.Time) string {
+	return humanize.Time(t)
+}
 
 // renders the 'home' template which is currently located at "start.html".
+// It is rendered once for each snapshot of the voting information.
 func (td *WebUI) homePage(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("X-Frame-Options", "DENY")
-	w.Header().Set("X-XSS-Protection", "1; mode=block")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.Header().Set("Referrer-Policy", "no-referrer")
-	err := td.templ.ExecuteTemplate(w, "home", td.TemplateData)
-	if err != nil {
-		log.Printf("Failed to Execute: %v", err)
-		return
+	t := td.TemplateData.Load()
+	td.writePage(w, r, t, "home", "home", t)
+}
+
+// router returns the handler for every URL path served by the web UI.
+func (td *WebUI) router() http.Handler {
+	mux := http.NewServeMux()
+
+	noDirListing := func(h http.Handler) http.HandlerFunc {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/") {
+				td.homePage(w, r)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	mux.HandleFunc("/", td.homePage)
+	mux.HandleFunc("GET /agenda/{id}", td.agendaPage)
+	mux.HandleFunc("GET /version/{version}", td.versionPage)
+	mux.HandleFunc("GET /tspends", td.tspendsPage)
+	mux.HandleFunc("GET /block/{block}", td.blockPage)
+
+	// JSON API, see docs/api.md
+	mux.HandleFunc("GET /api/v1/status", td.apiStatus)
+	mux.HandleFunc("GET /api/v1/pow", td.apiPoW)
+	mux.HandleFunc("GET /api/v1/stakeversionintervals", td.apiStakeVersionIntervals)
+	mux.HandleFunc("GET /api/v1/participation", td.apiParticipation)
+	mux.HandleFunc("GET /api/v1/agendas", td.apiAgendas)
+	mux.HandleFunc("GET /api/v1/agendas/{id}", td.apiAgenda)
+	mux.HandleFunc("GET /api/v1/agendas/{id}/progression", td.apiVoteProgression)
+	mux.HandleFunc("GET /api/v1/tspends", td.apiTSpends)
+	mux.HandleFunc("GET /api/v1/blocks/{block}/votes", td.apiBlockVotes)
+	mux.HandleFunc("GET /api/v1/events", td.apiEvents)
+
+	// Prometheus metrics, see docs/metrics.md
+	mux.HandleFunc("GET /metrics", td.metrics)
+
+	// Liveness and readiness probes
+	mux.HandleFunc("GET /healthz", td.healthz)
+	mux.HandleFunc("GET /readyz", td.readyz)
+
+	// URL handlers for js/css/fonts/images
+	mux.Handle("/js/", noDirListing(td.assets))
+	mux.Handle("/css/", noDirListing(td.assets))
+	mux.Handle("/fonts/", noDirListing(td.assets))
+	mux.Handle("/images/", noDirListing(td.assets))
+
+	return serviceMetrics.countRequests(mux)
+}
+
+// metrics serves the voting information and operational metrics in the
+// Prometheus text exposition format.
+func (td *WebUI) metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := serviceMetrics.write(w, td.TemplateData.Load()); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
 	}
-	// TODO: Use TemplateExecToString only when the template data is updated
-	// (i.e. block notification).
 }
 
 // WebUI represents the html web interface. It includes the template related
 // data, methods for parsing the templates, and the http.HandlerFuncs registered
 // with URL paths by the http router.
 type WebUI struct {
-	TemplateData *templateFields
-	templ        *template.Template
+	// TemplateData holds the snapshot of the voting information which is
+	// rendered.  Each request loads it once so that it is served a single,
+	// consistent, snapshot.
+	TemplateData *atomic.Pointer[templateFields]
+	// MaxUpdateAge is the maximum age of the voting information before
+	// /readyz reports dcrvotingweb is not ready.  Zero disables the check.
+	MaxUpdateAge time.Duration
+	// public holds the templates, in views, and the static assets.
+	public fs.FS
+	assets *staticAssets
+	// templ is replaced when the templates are reloaded while requests are
+	// being served.
+	templ     atomic.Pointer[template.Template]
+	responses responseCache
+	events    *eventBroker
+	blocks    *blockVotesCache
 }
 
 // NewWebUI is the constructor for WebUI.  It creates a html/template.Template,
-// loads the function map, and parses the template files.
-func NewWebUI() (*WebUI, error) {
-	tmpl, err := parseTemplates()
+// loads the function map, and parses the template files from public, which
+// also serves the static assets.
+func NewWebUI(public fs.FS) (*WebUI, error) {
+	td := &WebUI{
+		public: public,
+		assets: newStaticAssets(public),
+		events: newEventBroker(),
+		blocks: newBlockVotesCache(blockVotesCacheSize, maxBlockVotesLookups),
+	}
+	tmpl, err := td.parseTemplates()
 	if err != nil {
 		return nil, err
 	}
+	td.templ.Store(tmpl)
 
-	return &WebUI{
-		templ: tmpl,
-	}, nil
+	return td, nil
 }
 
-func parseTemplates() (*template.Template, error) {
-	fp := filepath.Join("public", "views", "*.html")
-	tmpl, err := template.New("home").Funcs(funcMap).ParseGlob(fp)
+func (td *WebUI) parseTemplates() (*template.Template, error) {
+	tmpl, err := template.New("home").Funcs(funcMap).Funcs(template.FuncMap{
+		"asset": td.assets.url,
+	}).ParseFS(td.public, "views/*.html")
 	if err != nil {
 		return nil, err
 	}
@@ -96,12 +177,12 @@ func (td *WebUI) reloadTemplatesSig(sig os.Signal) {
 			sigr := <-sigChan
 			log.Printf("Received %s", sig)
 			if sigr == sig {
-				tmpl, err := parseTemplates()
+				tmpl, err := td.parseTemplates()
 				if err != nil {
 					log.Println(err)
 					continue
 				}
-				td.templ = tmpl
+				td.templ.Store(tmpl)
 				log.Println("Web UI html templates reparsed.")
 			}
 		}